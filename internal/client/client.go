@@ -0,0 +1,370 @@
+// Package client implements the sgen client shared by the sgen command and
+// the public pkg/sgen package, which wraps it with its own types.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/scnewma/sgen/internal/hclconfig"
+	core "github.com/scnewma/sgen/internal/sgen"
+	"github.com/scnewma/sgen/internal/tplcache"
+)
+
+// DefaultParallelism is the maximum number of sources synced at the same time
+// unless the client is created with WithDefaultParallelism.
+const DefaultParallelism = 4
+
+// Client loads, syncs and generates output from sources, both those in an
+// sgen configuration and those registered with WithSource. It is safe for
+// concurrent use.
+type Client struct {
+	config      *hclconfig.Config
+	renderers   map[string]core.Renderer
+	cacheDir    string
+	tplCache    *tplcache.Cache
+	memory      *recordCache
+	background  func(names []string) error
+	parallelism int
+
+	mu    sync.Mutex
+	built map[string]*core.Source
+}
+
+type clientOptions struct {
+	config      *hclconfig.Config
+	cacheDir    string
+	sources     []*core.Source
+	renderers   map[string]core.Renderer
+	memory      bool
+	background  func(names []string) error
+	parallelism int
+}
+
+// Option configures a Client.
+type Option func(*clientOptions)
+
+// WithConfig uses a parsed configuration. Its diagnostics are left to the
+// caller.
+func WithConfig(config *hclconfig.Config) Option {
+	return func(opts *clientOptions) {
+		opts.config = config
+	}
+}
+
+// WithCacheDir caches synced data and rendered output in dir rather than in
+// DefaultCacheDir.
+func WithCacheDir(dir string) Option {
+	return func(opts *clientOptions) {
+		opts.cacheDir = dir
+	}
+}
+
+// WithSource registers a source that isn't in the configuration. Its name
+// must not clash with a configured source, and it, along with its inputs,
+// must be cached in the client's cache directory, see core.Source.CacheDir.
+// The renderers registered with WithRenderer are added to the source's.
+func WithSource(src *core.Source) Option {
+	return func(opts *clientOptions) {
+		opts.sources = append(opts.sources, src)
+	}
+}
+
+// WithRenderer registers a named renderer for every source, which can be
+// selected with WithNamedRenderer. Templates that a source defines with the
+// same name take precedence.
+func WithRenderer(name string, r core.Renderer) Option {
+	return func(opts *clientOptions) {
+		if opts.renderers == nil {
+			opts.renderers = make(map[string]core.Renderer)
+		}
+		opts.renderers[name] = r
+	}
+}
+
+// WithMemoryCache keeps the records of cached sources in memory between calls
+// to Generate, until the source is synced again. It's intended for long
+// running processes, like sgen serve.
+func WithMemoryCache() Option {
+	return func(opts *clientOptions) {
+		opts.memory = true
+	}
+}
+
+// WithBackgroundRefresh has Refresh call fn with the names of expired sources
+// rather than syncing them before returning, so stale data is served while
+// fn updates them. fn must not wait for the sources to sync.
+func WithBackgroundRefresh(fn func(names []string) error) Option {
+	return func(opts *clientOptions) {
+		opts.background = fn
+	}
+}
+
+// WithDefaultParallelism sets the maximum number of sources that are synced
+// at the same time when Sync isn't given WithParallelism, and by Refresh.
+func WithDefaultParallelism(n int) Option {
+	return func(opts *clientOptions) {
+		opts.parallelism = n
+	}
+}
+
+// New creates a Client. Without WithConfig the client only has the sources
+// registered with WithSource.
+func New(opts ...Option) (*Client, error) {
+	options := clientOptions{parallelism: DefaultParallelism}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	cacheDir := options.cacheDir
+	if cacheDir == "" {
+		var err error
+		if cacheDir, err = core.CacheDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	config := options.config
+	if config == nil {
+		config = &hclconfig.Config{Sources: map[string]hclconfig.Source{}}
+	}
+
+	c := &Client{
+		config:      config,
+		renderers:   options.renderers,
+		cacheDir:    cacheDir,
+		tplCache:    &tplcache.Cache{BaseDir: cacheDir},
+		background:  options.background,
+		parallelism: options.parallelism,
+		built:       make(map[string]*core.Source),
+	}
+	if options.memory {
+		c.memory = &recordCache{entries: make(map[string]recordCacheEntry)}
+	}
+
+	for _, src := range options.sources {
+		if _, found := config.Sources[src.Name]; found {
+			return nil, fmt.Errorf("source %q is both configured and registered", src.Name)
+		}
+		if _, found := c.built[src.Name]; found {
+			return nil, fmt.Errorf("source %q is registered more than once", src.Name)
+		}
+		src.Renderers = c.withRenderers(src.Renderers)
+		c.built[src.Name] = src
+	}
+	return c, nil
+}
+
+// DefaultConfigDir returns the directory the sgen command loads its
+// configuration from, $SGEN_CONFIG_DIR or ~/.config/sgen.
+func DefaultConfigDir() (string, error) {
+	if dir := os.Getenv("SGEN_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find HOME: %w", err)
+	}
+	return filepath.Join(dir, ".config", "sgen"), nil
+}
+
+// SourceNames returns the names of every source, sorted.
+func (c *Client) SourceNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var names []string
+	for name := range c.config.Sources {
+		seen[name] = true
+		names = append(names, name)
+	}
+	for name := range c.built {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Source returns the named source. Configured sources are built the first time
+// they are used.
+func (c *Client) Source(name string) (*core.Source, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.build(name)
+}
+
+// sources returns the named sources.
+func (c *Client) sources(names []string) ([]core.Source, error) {
+	srcs := make([]core.Source, 0, len(names))
+	for _, name := range names {
+		src, err := c.Source(name)
+		if err != nil {
+			return nil, err
+		}
+		srcs = append(srcs, *src)
+	}
+	return srcs, nil
+}
+
+// SourceStatus describes the data a source has.
+type SourceStatus struct {
+	// Cached is whether the source's data is cached, and so needs syncing.
+	Cached bool
+	// SyncedAt is when the source was last synced, nil if it never has been
+	// or it isn't cached.
+	SyncedAt *time.Time
+	// Items is the number of records the source has, nil if it has never
+	// been synced.
+	Items *int
+}
+
+// Status returns the status of the named source. Sources that aren't cached
+// are loaded to count their records.
+func (c *Client) Status(ctx context.Context, name string) (SourceStatus, error) {
+	src, err := c.Source(name)
+	if err != nil {
+		return SourceStatus{}, err
+	}
+
+	status := SourceStatus{Cached: src.Supplier.ShouldCache()}
+	if !status.Cached {
+		// uncached sources are cheap to read, so count them directly
+		data, err := src.Load(ctx)
+		if err != nil {
+			return status, err
+		}
+		items := len(data)
+		status.Items = &items
+		return status, nil
+	}
+
+	meta, err := core.SourceCacheIn(c.cacheDir).Meta(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return status, nil
+	} else if err != nil {
+		return status, err
+	}
+	status.SyncedAt = &meta.SyncedAt
+	status.Items = &meta.Count
+	return status, nil
+}
+
+// Load returns the records of the named source. Cached sources return an
+// error wrapping fs.ErrNotExist if they have never been synced.
+func (c *Client) Load(ctx context.Context, name string) ([]map[string]any, error) {
+	src, err := c.Source(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.loadSource(ctx, *src)
+}
+
+func (c *Client) loadSource(ctx context.Context, src core.Source) ([]map[string]any, error) {
+	if c.memory != nil {
+		return c.memory.Load(ctx, src)
+	}
+	return src.Load(ctx)
+}
+
+// withRenderers adds the renderers registered with WithRenderer to rndrs,
+// along with a JSON default renderer if there isn't one.
+func (c *Client) withRenderers(rndrs map[string]core.Renderer) map[string]core.Renderer {
+	all := make(map[string]core.Renderer, len(rndrs)+len(c.renderers)+1)
+	for name, r := range c.renderers {
+		all[name] = r
+	}
+	for name, r := range rndrs {
+		all[name] = r
+	}
+	if _, found := all["default"]; !found {
+		all["default"] = &core.JSONRenderer{}
+	}
+	return all
+}
+
+// build builds the named source from the config along with the sources it is
+// derived from. Each source is only built once so sources shared by several
+// others are the same *core.Source. c.mu must be held.
+func (c *Client) build(srcName string) (*core.Source, error) {
+	if src, found := c.built[srcName]; found {
+		return src, nil
+	}
+
+	cs, found := c.config.Sources[srcName]
+	if !found {
+		return nil, fmt.Errorf("source %q not configured", srcName)
+	}
+
+	var err error
+	rndrs := map[string]core.Renderer{}
+	docs := map[string]*core.DocumentRenderer{}
+	for name, tpl := range cs.GetTemplates() {
+		if cs.GetTemplateMode(name) == hclconfig.TemplateModeDocument {
+			docs[name], err = core.NewDocumentRenderer(tpl)
+		} else {
+			rndrs[name], err = core.NewGoTemplateRenderer(tpl)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// a default document template takes precedence over the JSON default
+	// renderer, see generateOptions.Document
+	rndrs = c.withRenderers(rndrs)
+	for name := range docs {
+		if name != "default" {
+			delete(rndrs, name)
+		}
+	}
+
+	// the config is checked for circular references when it is parsed, so
+	// this always terminates
+	var inputs []*core.Source
+	inputsByName := map[string]*core.Source{}
+	for _, name := range cs.GetInputs() {
+		input, err := c.build(name)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+		inputsByName[name] = input
+	}
+
+	supplier, err := cs.ToSupplier(inputsByName)
+	if err != nil {
+		return nil, err
+	}
+
+	var items *core.ItemRenderer
+	if alfred := cs.GetAlfred(); alfred != nil {
+		items, err = core.NewItemRenderer(alfred.ItemTemplates())
+		if err != nil {
+			return nil, fmt.Errorf("alfred %w", err)
+		}
+	}
+
+	src := &core.Source{
+		Name:      cs.GetName(),
+		Renderers: rndrs,
+		Documents: docs,
+		Supplier:  supplier,
+		TTL:       cs.GetTTL(),
+		SortBy:    cs.GetSortBy(),
+		Items:     items,
+		Inputs:    inputs,
+		CacheDir:  c.cacheDir,
+	}
+	c.built[srcName] = src
+	return src, nil
+}
@@ -0,0 +1,519 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/scnewma/sgen/internal/encoding"
+	"github.com/scnewma/sgen/internal/fieldpath"
+	"github.com/scnewma/sgen/internal/fuzzy"
+	"github.com/scnewma/sgen/internal/records"
+	core "github.com/scnewma/sgen/internal/sgen"
+)
+
+type generateOptions struct {
+	renderer      core.Renderer
+	document      *core.DocumentRenderer
+	namedRenderer string
+	filter        *records.Filter
+	sortBy        []string
+	reverse       bool
+	uniqueBy      string
+	limit         int
+	query         *fuzzy.Query
+	matchField    string
+	list          core.ListRenderer
+	noCache       bool
+	// combined is set when the records of all of the sources are processed
+	// together, see generateCombined.
+	combined bool
+}
+
+func (o generateOptions) Renderer(src core.Source) core.Renderer {
+	if o.renderer != nil {
+		return o.renderer
+	}
+	if o.namedRenderer != "" {
+		if rndr, found := src.Renderers[o.namedRenderer]; found {
+			return rndr
+		}
+		// fallthrough
+	}
+	return src.Renderers["default"]
+}
+
+// Document returns the document template to render src with, or nil if its
+// records are rendered one at a time with Renderer. Default document
+// templates are only used when each source is rendered on its own as a line
+// per record, otherwise the records are rendered with the default renderer,
+// which is JSON when the source's default template is a document.
+func (o generateOptions) Document(src core.Source) *core.DocumentRenderer {
+	if o.renderer != nil {
+		return nil
+	}
+	if o.document != nil {
+		return o.document
+	}
+	if o.namedRenderer != "" {
+		if _, found := src.Renderers[o.namedRenderer]; found {
+			return nil
+		}
+		if doc, found := src.Documents[o.namedRenderer]; found {
+			return doc
+		}
+	}
+	if o.list != nil || o.combined {
+		return nil
+	}
+	return src.Documents["default"]
+}
+
+// CacheKey identifies the output of the renderer with rendererID for src in
+// the template cache. Anything that changes which records are rendered, or
+// their order, must be part of the key.
+func (o generateOptions) CacheKey(src core.Source, rendererID string) string {
+	key := rendererID
+	if len(src.SortBy) > 0 {
+		key += "\x00source-sort-by:" + strings.Join(src.SortBy, ",")
+	}
+	if o.filter != nil {
+		key += "\x00where:" + o.filter.String()
+	}
+	if len(o.sortBy) > 0 {
+		key += fmt.Sprintf("\x00sort-by:%s:%t", strings.Join(o.sortBy, ","), o.reverse)
+	}
+	if o.uniqueBy != "" {
+		key += "\x00unique-by:" + o.uniqueBy
+	}
+	if o.limit > 0 {
+		key += fmt.Sprintf("\x00limit:%d", o.limit)
+	}
+	if o.list != nil {
+		key += "\x00output:" + o.list.ID()
+		if src.Items != nil {
+			key += src.Items.ID()
+		}
+	}
+	return key
+}
+
+// reorders reports whether the options change which records are output, or
+// their order, across sources. When they do, the records from all of the
+// sources have to be processed together rather than one source at a time.
+func (o generateOptions) reorders() bool {
+	return len(o.sortBy) > 0 || o.uniqueBy != "" || o.limit > 0
+}
+
+// GenerateOption configures Generate.
+type GenerateOption func(*generateOptions)
+
+// WithTemplate renders each record with r rather than the source's default
+// renderer.
+func WithTemplate(r core.Renderer) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.renderer = r
+	}
+}
+
+// WithoutCache renders without reading or writing the template cache, for
+// one-off templates whose output isn't worth keeping.
+func WithoutCache() GenerateOption {
+	return func(opts *generateOptions) {
+		opts.noCache = true
+	}
+}
+
+// WithDocument renders all of each source's records at once with r.
+func WithDocument(r *core.DocumentRenderer) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.document = r
+	}
+}
+
+// WithNamedRenderer renders each record with the source's renderer, or
+// document template, called name. Sources without one use their default.
+func WithNamedRenderer(name string) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.namedRenderer = name
+	}
+}
+
+// WithFilter only renders the records that match f.
+func WithFilter(f *records.Filter) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.filter = f
+	}
+}
+
+// WithSort renders records ordered by fields, see records.CompareBy.
+func WithSort(fields []string, reverse bool) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.sortBy = fields
+		opts.reverse = reverse
+	}
+}
+
+// WithUnique only renders the first record for each value of field.
+func WithUnique(field string) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.uniqueBy = field
+	}
+}
+
+// WithQuery only renders the records that fuzzy match q, best matches first.
+// Records are matched on the value of field or, when it is empty, on their
+// rendered output.
+func WithQuery(q *fuzzy.Query, field string) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.query = q
+		opts.matchField = field
+	}
+}
+
+// WithListRenderer renders the records as a single list of items, i.e. an
+// Alfred script filter document, rather than a line per record.
+func WithListRenderer(r core.ListRenderer) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.list = r
+	}
+}
+
+// WithLimit renders at most n records.
+func WithLimit(n int) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.limit = n
+	}
+}
+
+// Generate renders the records of the named sources to out, one source after
+// the other unless the options combine them.
+func (c *Client) Generate(ctx context.Context, out io.Writer, names []string, opts ...GenerateOption) error {
+	if len(names) == 0 {
+		return fmt.Errorf("at least one source is required")
+	}
+	srcs, err := c.sources(names)
+	if err != nil {
+		return err
+	}
+
+	var options generateOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	// query results depend on the terms, so they are never cached
+	// lists are a single document, so multiple sources can't be rendered
+	// one after the other
+	if options.query != nil || (len(srcs) > 1 && (options.reorders() || options.list != nil)) {
+		options.combined = true
+		return c.generateCombined(ctx, out, srcs, options)
+	}
+
+	for _, src := range srcs {
+		if err := c.generateSource(ctx, out, src, options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generateSource renders a single source, from the template cache if
+// possible. The source is locked while rendering so that a sync can't clear
+// the template cache before output rendered from the previous data is cached.
+func (c *Client) generateSource(ctx context.Context, out io.Writer, src core.Source, options generateOptions) error {
+	lock, err := src.RLock()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	rndr := options.Renderer(src)
+	doc := options.Document(src)
+	if doc != nil && options.list != nil {
+		return fmt.Errorf("document templates can't be used with --output")
+	}
+	cacheKey := options.CacheKey(src, rndr.ID())
+	if doc != nil {
+		cacheKey = options.CacheKey(src, doc.ID())
+	}
+
+	if cache, err := c.cachedOutput(src, cacheKey, options); err == nil && cache != nil {
+		// if an error happens copying the cached date into the writer we
+		// can't just fallback to loading the underlying source and using
+		// that data since we may have partially written the cached data,
+		// which would create corrupted output on the writer
+		_, err := io.Copy(out, bytes.NewBuffer(cache))
+		return err
+	}
+
+	data, err := c.load(ctx, src, options)
+	if err != nil {
+		return err
+	}
+	records.Sort(data, options.sortBy, options.reverse)
+	if options.uniqueBy != "" {
+		data = records.Unique(data, options.uniqueBy)
+	}
+	data = records.Limit(data, options.limit)
+
+	w, commit := out, func() error { return nil }
+	if !options.noCache {
+		cacheW, err := c.tplCache.Open(src.Name, cacheKey)
+		if err != nil {
+			return err
+		}
+		// discards the cached output unless it is committed below
+		defer cacheW.Close()
+		w, commit = io.MultiWriter(out, cacheW), cacheW.Commit
+	}
+	if doc != nil {
+		if err := renderDocument(w, doc, src, data); err != nil {
+			return err
+		}
+		return commit()
+	}
+	if options.list != nil {
+		items := make([]core.Item, 0, len(data))
+		for _, datum := range data {
+			item, err := listItem(src, rndr, datum, nil)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		if err := options.list.RenderList(w, items); err != nil {
+			return err
+		}
+		return commit()
+	}
+	for _, datum := range data {
+		if err := render(w, rndr, datum); err != nil {
+			return err
+		}
+	}
+	return commit()
+}
+
+// cachedOutput returns the output cached for src with cacheKey, or nil if
+// there is none or options skip the cache.
+func (c *Client) cachedOutput(src core.Source, cacheKey string, options generateOptions) ([]byte, error) {
+	if options.noCache {
+		return nil, nil
+	}
+	return c.tplCache.Get(src.Name, cacheKey)
+}
+
+// generateCombined sorts, ranks, de-duplicates and limits the records of all
+// of the sources together. Each record is still rendered with its own source's
+// renderer. The output isn't cached since the template cache is per source.
+func (c *Client) generateCombined(ctx context.Context, out io.Writer, srcs []core.Source, options generateOptions) error {
+	type item struct {
+		src    core.Source
+		rndr   core.Renderer
+		record map[string]any
+		// line is the rendered record, if it had to be rendered to be matched
+		// against the query
+		line  *string
+		score int
+	}
+
+	var items []item
+	for _, src := range srcs {
+		if options.Document(src) == nil {
+			continue
+		}
+		if options.query != nil {
+			return fmt.Errorf("document templates render all of a source's records at once, so they can't be used with queries")
+		}
+		return fmt.Errorf("document templates render one source at a time, so they can't be used to sort, de-duplicate or limit several sources together")
+	}
+	for _, src := range srcs {
+		data, err := c.load(ctx, src, options)
+		if err != nil {
+			return err
+		}
+		rndr := options.Renderer(src)
+		for _, datum := range data {
+			items = append(items, item{src: src, rndr: rndr, record: datum})
+		}
+	}
+
+	if len(options.sortBy) > 0 {
+		sort.SliceStable(items, func(i, j int) bool {
+			a, b := items[i].record, items[j].record
+			if options.reverse {
+				a, b = b, a
+			}
+			return records.CompareBy(a, b, options.sortBy) < 0
+		})
+	}
+	if options.query != nil {
+		matched := items[:0]
+		for _, it := range items {
+			var text string
+			if options.matchField != "" {
+				var ok bool
+				if text, ok = matchText(it.record, options.matchField); !ok {
+					continue
+				}
+			} else {
+				line, err := it.rndr.Render(it.record)
+				if err != nil {
+					return renderError(it.record, err)
+				}
+				it.line, text = &line, line
+			}
+			if score, ok := options.query.Score(text); ok {
+				it.score = score
+				matched = append(matched, it)
+			}
+		}
+		// stable, so equally good matches keep their order
+		sort.SliceStable(matched, func(i, j int) bool {
+			return matched[i].score > matched[j].score
+		})
+		items = matched
+	}
+	if options.uniqueBy != "" {
+		seen := map[string]bool{}
+		unique := items[:0]
+		for _, it := range items {
+			if key, ok := records.UniqueKey(it.record, options.uniqueBy); ok {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			unique = append(unique, it)
+		}
+		items = unique
+	}
+	if options.limit > 0 && len(items) > options.limit {
+		items = items[:options.limit]
+	}
+
+	if options.list != nil {
+		listItems := make([]core.Item, 0, len(items))
+		for _, it := range items {
+			li, err := listItem(it.src, it.rndr, it.record, it.line)
+			if err != nil {
+				return err
+			}
+			listItems = append(listItems, li)
+		}
+		return options.list.RenderList(out, listItems)
+	}
+	for _, it := range items {
+		if it.line != nil {
+			if _, err := fmt.Fprintln(out, *it.line); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := render(out, it.rndr, it.record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load returns the source's records in the source's configured order with the
+// filter applied.
+func (c *Client) load(ctx context.Context, src core.Source, options generateOptions) ([]map[string]any, error) {
+	data, err := c.loadSource(ctx, src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("generation requested for source without cached data, re-run with --sync to load data")
+	} else if err != nil {
+		return nil, fmt.Errorf("syncing %s: %w", src.Name, err)
+	}
+
+	records.Sort(data, src.SortBy, false)
+	if options.filter != nil {
+		data = options.filter.Apply(data)
+	}
+	return data, nil
+}
+
+func render(w io.Writer, rndr core.Renderer, datum map[string]any) error {
+	line, err := rndr.Render(datum)
+	if err != nil {
+		return renderError(datum, err)
+	}
+
+	_, err = fmt.Fprintln(w, line)
+	return err
+}
+
+// listItem renders record as a list item. line is the record already rendered
+// by rndr, if it has been.
+func listItem(src core.Source, rndr core.Renderer, record map[string]any, line *string) (core.Item, error) {
+	if line == nil {
+		l, err := rndr.Render(record)
+		if err != nil {
+			return core.Item{}, renderError(record, err)
+		}
+		line = &l
+	}
+	if src.Items == nil {
+		return core.Item{Title: *line, Arg: *line}, nil
+	}
+	item, err := src.Items.Render(record, *line)
+	if err != nil {
+		return core.Item{}, renderError(record, err)
+	}
+	return item, nil
+}
+
+// matchText returns the value of field to match a query against. Records
+// where the field is missing, or isn't a string, number or boolean, never
+// match.
+func matchText(record map[string]any, field string) (string, bool) {
+	v, _ := fieldpath.Lookup(record, field)
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number, float64, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// renderDocument renders all of src's records with doc.
+func renderDocument(w io.Writer, doc *core.DocumentRenderer, src core.Source, data []map[string]any) error {
+	document := core.Document{
+		Source: src.Name,
+		Items:  data,
+		Count:  len(data),
+	}
+	if src.Supplier.ShouldCache() {
+		syncedAt, err := src.SyncedAt()
+		if err != nil {
+			return err
+		}
+		document.SyncedAt = &syncedAt
+	}
+
+	out, err := doc.Render(document)
+	if err != nil {
+		return fmt.Errorf("render failure for source %q: %w", src.Name, err)
+	}
+	_, err = io.WriteString(w, out)
+	return err
+}
+
+func renderError(datum map[string]any, err error) error {
+	dataStr, encErr := encoding.EncodeJSONString(datum)
+	if encErr != nil {
+		dataStr = "<encoding JSON failure>"
+	}
+
+	return fmt.Errorf("render failure with data %q: %w", dataStr, err)
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	core "github.com/scnewma/sgen/internal/sgen"
+)
+
+// recordCache keeps the records of cached sources in memory until the source
+// is synced again, by this client or any other process.
+type recordCache struct {
+	mu      sync.Mutex
+	entries map[string]recordCacheEntry
+}
+
+type recordCacheEntry struct {
+	syncedAt time.Time
+	data     []map[string]any
+}
+
+// Load returns the source's records. The slice is a copy, so it can be
+// reordered, but the records themselves are shared and must not be modified.
+func (c *recordCache) Load(ctx context.Context, src core.Source) ([]map[string]any, error) {
+	if !src.Supplier.ShouldCache() {
+		return src.Load(ctx)
+	}
+
+	syncedAt, err := src.SyncedAt()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	entry, found := c.entries[src.Name]
+	c.mu.Unlock()
+	if !found || !entry.syncedAt.Equal(syncedAt) {
+		data, err := src.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		entry = recordCacheEntry{syncedAt: syncedAt, data: data}
+
+		c.mu.Lock()
+		c.entries[src.Name] = entry
+		c.mu.Unlock()
+	}
+	return append([]map[string]any(nil), entry.data...), nil
+}
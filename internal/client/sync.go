@@ -0,0 +1,272 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	core "github.com/scnewma/sgen/internal/sgen"
+)
+
+type syncOptions struct {
+	parallelism int
+	progress    io.Writer
+}
+
+// SyncOption configures Sync.
+type SyncOption func(*syncOptions)
+
+// WithParallelism sets the maximum number of sources that are synced at the
+// same time.
+func WithParallelism(n int) SyncOption {
+	return func(opts *syncOptions) {
+		opts.parallelism = n
+	}
+}
+
+// WithProgress writes a line to w as each source finishes syncing, followed by
+// a summary table once all of the sources are done.
+func WithProgress(w io.Writer) SyncOption {
+	return func(opts *syncOptions) {
+		opts.progress = w
+	}
+}
+
+type syncResult struct {
+	source   core.Source
+	items    int
+	duration time.Duration
+	err      error
+}
+
+// Sync updates the named sources, or every source when there are none,
+// concurrently. A failure to sync one source does not stop the others from
+// syncing; the returned error names every source that failed. Sources derived
+// from other sources are synced after them, along with any of those sources
+// that weren't named.
+func (c *Client) Sync(ctx context.Context, names []string, opts ...SyncOption) error {
+	if len(names) == 0 {
+		names = c.SourceNames()
+	}
+	srcs, err := c.sources(names)
+	if err != nil {
+		return err
+	}
+	// derived sources can only be synced from their inputs' data, which may
+	// never have been synced
+	srcs = withInputs(srcs)
+
+	options := syncOptions{parallelism: c.parallelism}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return c.sync(ctx, srcs, options)
+}
+
+func (c *Client) sync(ctx context.Context, srcs []core.Source, options syncOptions) error {
+	if len(srcs) == 0 {
+		return nil
+	}
+	if options.parallelism < 1 {
+		options.parallelism = 1
+	}
+
+	// sources wait for any of their inputs that are being synced too, so they
+	// are derived from the latest data
+	synced := make(map[string]chan struct{}, len(srcs))
+	for _, src := range srcs {
+		synced[src.Name] = make(chan struct{})
+	}
+
+	var (
+		results = make([]syncResult, len(srcs))
+		failed  = make(map[string]bool)
+		sem     = make(chan struct{}, options.parallelism)
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		done    int
+	)
+	for i, src := range srcs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(synced[src.Name])
+
+			var inputErr error
+			for _, input := range src.Inputs {
+				ch, found := synced[input.Name]
+				if !found {
+					continue
+				}
+				<-ch
+				mu.Lock()
+				if failed[input.Name] && inputErr == nil {
+					inputErr = fmt.Errorf("input source %q failed to sync", input.Name)
+				}
+				mu.Unlock()
+			}
+
+			start := time.Now()
+			var items int
+			err := inputErr
+			if err == nil {
+				sem <- struct{}{}
+				items, err = c.syncSource(ctx, src)
+				<-sem
+			}
+			results[i] = syncResult{
+				source:   src,
+				items:    items,
+				duration: time.Since(start),
+				err:      err,
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed[src.Name] = true
+			}
+			if options.progress != nil {
+				done++
+				writeProgress(options.progress, done, len(srcs), results[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if options.progress != nil {
+		writeSyncSummary(options.progress, results)
+	}
+
+	var failedNames []string
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			failedNames = append(failedNames, r.source.Name)
+			errs = append(errs, fmt.Errorf("%s: %w", r.source.Name, r.err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to sync %d of %d sources [%s]:\n%w", len(failedNames), len(srcs), strings.Join(failedNames, ","), errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c *Client) syncSource(ctx context.Context, src core.Source) (int, error) {
+	// output rendered from the previous data is cleared while the source is
+	// still locked, so it can't be cached again from the previous data
+	return src.Sync(ctx, func() error {
+		return c.tplCache.Clear(src.Name)
+	})
+}
+
+func writeProgress(w io.Writer, done, total int, r syncResult) {
+	if r.err != nil {
+		fmt.Fprintf(w, "[%d/%d] %s failed after %s\n", done, total, r.source.Name, r.duration.Round(time.Millisecond))
+		return
+	}
+	if !r.source.Supplier.ShouldCache() {
+		fmt.Fprintf(w, "[%d/%d] %s skipped, it is not cached\n", done, total, r.source.Name)
+		return
+	}
+	fmt.Fprintf(w, "[%d/%d] %s synced in %s\n", done, total, r.source.Name, r.duration.Round(time.Millisecond))
+}
+
+func writeSyncSummary(w io.Writer, results []syncResult) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tSTATUS\tITEMS\tDURATION\tERROR")
+	for _, r := range results {
+		status, items, errStr := "ok", fmt.Sprint(r.items), ""
+		if !r.source.Supplier.ShouldCache() {
+			// uncached sources are read directly when generating
+			status, items = "skipped", "-"
+		}
+		if r.err != nil {
+			status, items = "failed", "-"
+			// keep the table to one line per source, the full error is
+			// returned from Sync
+			errStr, _, _ = strings.Cut(r.err.Error(), "\n")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.source.Name, status, items, r.duration.Round(time.Millisecond), errStr)
+	}
+	tw.Flush()
+}
+
+// Refresh updates any sources, or the sources they are derived from, whose
+// cached data is older than their TTL or than the data of their inputs.
+// Expired sources are synced before returning unless the client was created
+// with WithBackgroundRefresh and fresh isn't set, in which case they are
+// refreshed in the background while their stale data continues to be served.
+// Sources with a TTL that have never been synced are always synced before
+// returning, since there is no data to serve in the meantime.
+func (c *Client) Refresh(ctx context.Context, names []string, fresh bool) error {
+	srcs, err := c.sources(names)
+	if err != nil {
+		return err
+	}
+	cache := core.SourceCacheIn(c.cacheDir)
+
+	var foreground []core.Source
+	var background []string
+	for _, src := range withInputs(srcs) {
+		expired, err := src.Expired()
+		if errors.Is(err, fs.ErrNotExist) {
+			foreground = append(foreground, src)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !expired {
+			continue
+		}
+
+		if fresh || c.background == nil {
+			foreground = append(foreground, src)
+			continue
+		}
+
+		started, err := cache.StartRefresh(src.Name)
+		if err != nil {
+			return err
+		}
+		if started {
+			background = append(background, src.Name)
+		}
+	}
+
+	if len(background) > 0 {
+		if err := c.background(background); err != nil {
+			return fmt.Errorf("starting background refresh: %w", err)
+		}
+	}
+	return c.sync(ctx, foreground, syncOptions{parallelism: c.parallelism})
+}
+
+// withInputs returns srcs along with every source they are derived from,
+// directly or through other sources. Inputs come before the sources derived
+// from them.
+func withInputs(srcs []core.Source) []core.Source {
+	var all []core.Source
+	seen := make(map[string]bool)
+	var add func(src core.Source)
+	add = func(src core.Source) {
+		if seen[src.Name] {
+			return
+		}
+		seen[src.Name] = true
+		for _, input := range src.Inputs {
+			add(*input)
+		}
+		all = append(all, src)
+	}
+	for _, src := range srcs {
+		add(src)
+	}
+	return all
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scnewma/sgen/internal/client"
+	"github.com/scnewma/sgen/internal/hclconfig"
+	"github.com/scnewma/sgen/internal/records"
+)
+
+type sourceDescription struct {
+	sourceSummary
+	TypeSummary string          `json:"type_summary,omitempty"`
+	DefinedAt   string          `json:"defined_at"`
+	Config      string          `json:"config"`
+	Fields      []records.Field `json:"fields"`
+	Sample      map[string]any  `json:"sample,omitempty"`
+}
+
+func newDescribeCommand(g *globalOptions) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "describe SOURCE",
+		Short: "Show the fields, a sample item and the configuration of a source",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutput(output); err != nil {
+				return err
+			}
+
+			desc, err := describeSource(g.config, args[0])
+			if err != nil {
+				return err
+			}
+
+			if output == outputJSON {
+				return writeJSON(os.Stdout, desc)
+			}
+			return writeDescription(os.Stdout, desc)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "output format, one of [text,json]")
+	return cmd
+}
+
+func describeSource(config *hclconfig.Config, name string) (*sourceDescription, error) {
+	cs, found := config.Sources[name]
+	if !found {
+		return nil, fmt.Errorf("source %q not configured", name)
+	}
+
+	c, err := client.New(client.WithConfig(config))
+	if err != nil {
+		return nil, err
+	}
+
+	rng := cs.GetSrcRange()
+	desc := &sourceDescription{
+		sourceSummary: summarizeSource(config, c, name),
+		DefinedAt:     fmt.Sprintf("%s:%d", rng.Filename, rng.Start.Line),
+		Fields:        []records.Field{},
+	}
+	if st, found := config.LookupSourceType(cs.GetType()); found {
+		desc.TypeSummary = st.Summary
+	}
+	if f, found := config.Files[rng.Filename]; found {
+		desc.Config = string(rng.SliceBytes(f.Bytes))
+	}
+	if desc.Error != "" {
+		return desc, nil
+	}
+
+	data, err := c.Load(context.Background(), name)
+	if errors.Is(err, fs.ErrNotExist) {
+		// never synced, there is nothing to describe but the config
+		return desc, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	desc.Fields = records.Fields(data)
+	if len(data) > 0 {
+		desc.Sample = data[0]
+	}
+	return desc, nil
+}
+
+func writeDescription(w io.Writer, desc *sourceDescription) error {
+	fmt.Fprintf(w, "Name:       %s\n", desc.Name)
+	fmt.Fprintf(w, "Type:       %s\n", desc.Type)
+	if desc.TypeSummary != "" {
+		fmt.Fprintf(w, "            %s\n", desc.TypeSummary)
+	}
+	fmt.Fprintf(w, "Defined at: %s\n", desc.DefinedAt)
+	if len(desc.Templates) > 0 {
+		fmt.Fprintf(w, "Templates:  %s\n", strings.Join(desc.Templates, ", "))
+	}
+	if desc.TTL != "" {
+		fmt.Fprintf(w, "TTL:        %s\n", desc.TTL)
+	}
+	switch {
+	case desc.SyncedAt != nil:
+		fmt.Fprintf(w, "Synced:     %s (%s ago)\n", desc.SyncedAt.Local().Format(time.DateTime), formatAge(*desc.SyncedAt))
+	case desc.Cached:
+		fmt.Fprintf(w, "Synced:     never, run `sgen --sync %s` to load data\n", desc.Name)
+	}
+	if desc.Items != nil {
+		fmt.Fprintf(w, "Items:      %d\n", *desc.Items)
+	}
+	if desc.Error != "" {
+		fmt.Fprintf(w, "Error:      %s\n", desc.Error)
+	}
+
+	if len(desc.Fields) > 0 {
+		fmt.Fprintln(w, "\nFields:")
+		width := 0
+		for _, f := range desc.Fields {
+			width = max(width, len(f.Path))
+		}
+		for _, f := range desc.Fields {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, f.Path, strings.Join(f.Types, "|"))
+		}
+	}
+
+	if desc.Sample != nil {
+		fmt.Fprintln(w, "\nSample:")
+		var b strings.Builder
+		if err := writeJSON(&b, desc.Sample); err != nil {
+			return err
+		}
+		fmt.Fprint(w, indent(b.String(), "  "))
+	}
+
+	if desc.Config != "" {
+		fmt.Fprintln(w, "\nConfig:")
+		fmt.Fprintln(w, indent(desc.Config, "  "))
+	}
+	return nil
+}
+
+func indent(s, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
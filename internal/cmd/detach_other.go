@@ -0,0 +1,7 @@
+//go:build !unix
+
+package cmd
+
+import "os/exec"
+
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scnewma/sgen/internal/client"
+	"github.com/scnewma/sgen/internal/hclconfig"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+type sourceSummary struct {
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	Templates []string   `json:"templates"`
+	TTL       string     `json:"ttl,omitempty"`
+	Cached    bool       `json:"cached"`
+	SyncedAt  *time.Time `json:"synced_at,omitempty"`
+	Items     *int       `json:"items,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+func newListCommand(g *globalOptions) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the configured sources",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutput(output); err != nil {
+				return err
+			}
+
+			var names []string
+			for name := range g.config.Sources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			c, err := client.New(client.WithConfig(g.config))
+			if err != nil {
+				return err
+			}
+			summaries := make([]sourceSummary, 0, len(names))
+			for _, name := range names {
+				summaries = append(summaries, summarizeSource(g.config, c, name))
+			}
+
+			if output == outputJSON {
+				return writeJSON(os.Stdout, summaries)
+			}
+			return writeSourceTable(os.Stdout, summaries)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "output format, one of [text,json]")
+	return cmd
+}
+
+func summarizeSource(config *hclconfig.Config, c *client.Client, name string) sourceSummary {
+	cs := config.Sources[name]
+	summary := sourceSummary{
+		Name:      name,
+		Type:      cs.GetType(),
+		Templates: templateNames(cs),
+	}
+	if ttl := cs.GetTTL(); ttl > 0 {
+		summary.TTL = ttl.String()
+	}
+
+	status, err := c.Status(context.Background(), name)
+	summary.Cached = status.Cached
+	summary.SyncedAt = status.SyncedAt
+	summary.Items = status.Items
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	return summary
+}
+
+func templateNames(cs hclconfig.Source) []string {
+	names := []string{}
+	for name := range cs.GetTemplates() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeSourceTable(w io.Writer, summaries []sourceSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tTEMPLATES\tTTL\tCACHE AGE\tITEMS\tERROR")
+	for _, s := range summaries {
+		templates, ttl, age, items := "-", "-", "-", "-"
+		if len(s.Templates) > 0 {
+			templates = strings.Join(s.Templates, ",")
+		}
+		if s.TTL != "" {
+			ttl = s.TTL
+		}
+		if s.Cached {
+			age = "never synced"
+		}
+		if s.SyncedAt != nil {
+			age = formatAge(*s.SyncedAt)
+		}
+		if s.Items != nil {
+			items = fmt.Sprint(*s.Items)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Type, templates, ttl, age, items, s.Error)
+	}
+	return tw.Flush()
+}
+
+func formatAge(t time.Time) string {
+	return time.Since(t).Round(time.Second).String()
+}
+
+func validateOutput(output string) error {
+	if output != outputText && output != outputJSON {
+		return fmt.Errorf("invalid output %q, valid outputs are [%s,%s]", output, outputText, outputJSON)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultQueryLimit = 20
+
+func newQueryCommand(g *globalOptions) *cobra.Command {
+	var (
+		fresh bool
+		req   = generateRequest{}
+	)
+
+	cmd := &cobra.Command{
+		Use:   "query SOURCE [TERM ...]",
+		Short: "Fuzzy search a source, best matches first",
+		Long: `Fuzzy search a source, best matches first.
+
+Items must match every term, in any order. Terms are matched case
+insensitively unless they contain an upper case character. Items are matched
+on their rendered output unless --match-field is given.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.query = strings.Join(args[1:], " ")
+			opts, err := req.options()
+			if err != nil {
+				return err
+			}
+
+			app, err := NewSGen(SGenOpts{
+				Config:  g.config,
+				Sources: args[:1],
+			})
+			if err != nil {
+				return err
+			}
+			if err := app.Refresh(fresh); err != nil {
+				return err
+			}
+
+			bw := bufio.NewWriter(os.Stdout)
+			defer bw.Flush()
+			return app.Generate(bw, opts...)
+		},
+	}
+	cmd.Flags().StringVar(&req.matchField, "match-field", "", "match terms against this field instead of the rendered output")
+	cmd.Flags().IntVar(&req.limit, "limit", defaultQueryLimit, "render at most this many items, 0 for all of them")
+	cmd.Flags().StringVarP(&req.template, "template", "t", "", "go template for rendering each source item")
+	cmd.Flags().StringVarP(&req.namedTemplate, "template-name", "n", "", "name of the template defined in config.hcl to use for rendering each source item")
+	cmd.Flags().StringVarP(&req.where, "where", "w", "", "only match items matching the expression")
+	cmd.Flags().StringVarP(&req.output, "output", "o", outputLines, outputUsage)
+	cmd.Flags().BoolVar(&fresh, "fresh", false, "update the source if its data is older than its ttl before searching, instead of in the background")
+	return cmd
+}
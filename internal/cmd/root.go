@@ -7,18 +7,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/spf13/cobra"
 
-	"github.com/scnewma/sgen/internal/encoding"
+	"github.com/scnewma/sgen/internal/client"
+	"github.com/scnewma/sgen/internal/fuzzy"
 	"github.com/scnewma/sgen/internal/hclconfig"
-	"github.com/scnewma/sgen/internal/sgen"
-	"github.com/scnewma/sgen/internal/sgen/supply"
-	"github.com/scnewma/sgen/internal/tplcache"
+	"github.com/scnewma/sgen/internal/records"
+	core "github.com/scnewma/sgen/internal/sgen"
 )
 
 type ExitCodeError struct {
@@ -42,36 +42,125 @@ func Execute() int {
 	return 0
 }
 
-func execute() error {
-	path, err := ConfigFile()
+// globalOptions are shared by the root command and its subcommands. The
+// config is loaded once flags have been parsed since --var changes it.
+type globalOptions struct {
+	vars   []string
+	config *hclconfig.Config
+}
+
+func (g *globalOptions) loadConfig() error {
+	dir, err := ConfigDir()
+	if err != nil {
+		return err
+	}
+
+	flagVars, err := parseVarFlags(g.vars)
 	if err != nil {
 		return err
 	}
-	config, diags := hclconfig.Parse(path)
+	vars := envVariables()
+	for k, v := range flagVars {
+		vars[k] = v
+	}
+
+	config, diags := hclconfig.ParseDir(dir, hclconfig.WithVariables(vars))
 	if err := writeDiags(diags, config.Files); err != nil {
 		return err
 	}
+	for name := range flagVars {
+		if _, found := config.Variables[name]; !found {
+			return fmt.Errorf("variable %q is not declared in the configuration", name)
+		}
+	}
+
+	// background refreshes run in a new process that only sees the
+	// environment, so pass --var values down as SGEN_VAR_* variables
+	for k, v := range flagVars {
+		if err := os.Setenv(envVarPrefix+k, v); err != nil {
+			return err
+		}
+	}
+
+	g.config = config
+	return nil
+}
+
+const envVarPrefix = "SGEN_VAR_"
+
+// outputLines is the default output when generating, a line per record. The
+// other outputs are core.ListFormats.
+const outputLines = "lines"
+
+// envVariables returns the variable values set through SGEN_VAR_*
+// environment variables.
+func envVariables() map[string]string {
+	vars := make(map[string]string)
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if name, found := strings.CutPrefix(k, envVarPrefix); found && name != "" {
+			vars[name] = v
+		}
+	}
+	return vars
+}
+
+// parseVarFlags parses --var flags in the form key=value.
+func parseVarFlags(flags []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, flag := range flags {
+		k, v, found := strings.Cut(flag, "=")
+		if k = strings.TrimSpace(k); !found || k == "" {
+			return nil, fmt.Errorf("invalid --var %q, expected key=value", flag)
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+func execute() error {
+	g := &globalOptions{}
 
 	// flags
 	var (
 		sync          bool
+		syncOnly      bool
+		fresh         bool
+		parallelism   int
 		template      string
 		namedTemplate string
+		templateFile  string
+		where         string
+		sortBy        []string
+		reverse       bool
+		uniqueBy      string
+		limit         int
+		output        string
 	)
 
 	root := &cobra.Command{
 		Use:           "sgen [SOURCE ...]",
+		Args:          cobra.ArbitraryArgs,
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return g.loadConfig()
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if !sync && len(args) == 0 {
-				return cmd.Usage()
+			config := g.config
+			if syncOnly {
+				app, err := NewSGen(SGenOpts{
+					Config:  config,
+					Sources: args,
+				})
+				if err != nil {
+					return err
+				}
+				return app.Sync(client.WithParallelism(parallelism))
 			}
 
-			template = strings.TrimSpace(template)
-			namedTemplate = strings.TrimSpace(namedTemplate)
-			if template != "" && namedTemplate != "" {
-				return fmt.Errorf("--template and --template-name are mutually exclusive")
+			if !sync && len(args) == 0 {
+				return cmd.Usage()
 			}
 
 			// special case, if the user just specifies -S then we sync all of
@@ -81,6 +170,7 @@ func execute() error {
 				for _, cs := range config.Sources {
 					sources = append(sources, cs.GetName())
 				}
+				sort.Strings(sources)
 
 				app, err := NewSGen(SGenOpts{
 					Config:  config,
@@ -89,7 +179,7 @@ func execute() error {
 				if err != nil {
 					return err
 				}
-				return app.Sync()
+				return app.Sync(client.WithParallelism(parallelism), client.WithProgress(os.Stderr))
 			}
 
 			app, err := NewSGen(SGenOpts{
@@ -101,20 +191,26 @@ func execute() error {
 			}
 
 			if sync {
-				if err := app.Sync(); err != nil {
+				if err := app.Sync(client.WithParallelism(parallelism), client.WithProgress(os.Stderr)); err != nil {
 					return err
 				}
+			} else if err := app.Refresh(fresh); err != nil {
+				return err
 			}
 
-			opts := []GenerateOption{}
-			if template != "" {
-				renderer, err := sgen.NewGoTemplateRenderer(template)
-				if err != nil {
-					return err
-				}
-				opts = append(opts, WithRenderer(renderer))
-			} else if namedTemplate != "" {
-				opts = append(opts, WithNamedRenderer(namedTemplate))
+			opts, err := generateRequest{
+				template:      template,
+				namedTemplate: namedTemplate,
+				templateFile:  templateFile,
+				where:         where,
+				sortBy:        sortBy,
+				reverse:       reverse,
+				uniqueBy:      uniqueBy,
+				limit:         limit,
+				output:        output,
+			}.options()
+			if err != nil {
+				return err
 			}
 
 			bw := bufio.NewWriter(os.Stdout)
@@ -123,13 +219,37 @@ func execute() error {
 		},
 	}
 
+	root.PersistentFlags().StringArrayVar(&g.vars, "var", nil, "set a variable declared in the configuration, i.e. --var org=scnewma")
 	root.Flags().BoolVarP(&sync, "sync", "S", false, "update sources")
+	root.Flags().IntVar(&parallelism, "parallelism", client.DefaultParallelism, "maximum number of sources to sync at the same time")
+	root.Flags().BoolVar(&fresh, "fresh", false, "update sources whose data is older than their ttl before generating, instead of in the background")
+	// used to refresh expired sources in the background, see SGen.Refresh
+	root.Flags().BoolVar(&syncOnly, "sync-only", false, "update sources without generating any output")
+	_ = root.Flags().MarkHidden("sync-only")
 	root.Flags().StringVarP(&template, "template", "t", "", "go template for rendering each source item, see: http://golang.org/pkg/text/template/#pkg-overview")
+	root.Flags().StringVarP(&where, "where", "w", "", `only render items matching the expression, i.e. 'visibility == "private" && name matches "^api-"'`)
+	root.Flags().StringSliceVar(&sortBy, "sort-by", nil, "sort items by the comma separated fields")
+	root.Flags().BoolVar(&reverse, "reverse", false, "reverse the order of --sort-by")
+	root.Flags().StringVar(&uniqueBy, "unique-by", "", "only render the first item for each value of the field")
+	root.Flags().IntVar(&limit, "limit", 0, "render at most this many items")
 	root.Flags().StringVarP(&namedTemplate, "template-name", "n", "", "name of the template defined in config.hcl to use for rendering each source item")
+	root.Flags().StringVar(&templateFile, "template-file", "", "file with a go template for rendering all of each source's items at once, see document templates")
+	root.Flags().StringVarP(&output, "output", "o", outputLines, outputUsage)
+
+	root.AddCommand(
+		newListCommand(g),
+		newDescribeCommand(g),
+		newValidateCommand(g),
+		newServeCommand(g),
+		newQueryCommand(g),
+		newTypesCommand(g),
+	)
 
 	return root.Execute()
 }
 
+var outputUsage = fmt.Sprintf("output format, one of [%s,%s], see the alfred source block", outputLines, strings.Join(core.ListFormats, ","))
+
 func writeDiags(diags hcl.Diagnostics, files map[string]*hcl.File) error {
 	var b bytes.Buffer
 	w := hcl.NewDiagnosticTextWriter(&b, files, 80, true)
@@ -145,9 +265,10 @@ func writeDiags(diags hcl.Diagnostics, files map[string]*hcl.File) error {
 	return nil
 }
 
+// SGen runs the sgen command against the sources named on the command line.
 type SGen struct {
-	Sources  []sgen.Source
-	TplCache *tplcache.Cache
+	Sources []string
+	client  *client.Client
 }
 
 type SGenOpts struct {
@@ -156,185 +277,142 @@ type SGenOpts struct {
 }
 
 func NewSGen(opts SGenOpts) (*SGen, error) {
-	var srcs []sgen.Source
-	for _, srcName := range opts.Sources {
-		var err error
-
-		cs, found := opts.Config.Sources[srcName]
-		if !found {
-			return nil, fmt.Errorf("source %q not configured", srcName)
-		}
-
-		rndrs := map[string]sgen.Renderer{}
-		for name, tpl := range cs.GetTemplates() {
-			rndrs[name], err = sgen.NewGoTemplateRenderer(tpl)
-			if err != nil {
-				return nil, err
-			}
-		}
-		if _, found := rndrs["default"]; !found {
-			rndrs["default"] = &sgen.JSONRenderer{}
-		}
-
-		supplier, err := cs.ToSupplier()
-		if err != nil {
+	c, err := client.New(
+		client.WithConfig(opts.Config),
+		client.WithBackgroundRefresh(refreshInBackground),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// sources are built up front so configuration errors are reported before
+	// anything is synced
+	for _, name := range opts.Sources {
+		if _, err := c.Source(name); err != nil {
 			return nil, err
 		}
-
-		srcs = append(srcs, sgen.Source{
-			Name:      cs.GetName(),
-			Renderers: rndrs,
-			Supplier:  supplier,
-		})
 	}
-	return &SGen{
-		Sources:  srcs,
-		TplCache: tplcache.New(),
-	}, nil
-}
-
-type generateOptions struct {
-	renderer      sgen.Renderer
-	namedRenderer string
+	return &SGen{Sources: opts.Sources, client: c}, nil
 }
 
-func (o generateOptions) Renderer(src sgen.Source) sgen.Renderer {
-	if o.renderer != nil {
-		return o.renderer
+// Sync syncs the sources, see client.Client.Sync.
+func (s *SGen) Sync(opts ...client.SyncOption) error {
+	if len(s.Sources) == 0 {
+		return nil
 	}
-	if o.namedRenderer != "" {
-		if rndr, found := src.Renderers[o.namedRenderer]; found {
-			return rndr
-		}
-		// fallthrough
-	}
-	return src.Renderers["default"]
+	return s.client.Sync(context.Background(), s.Sources, opts...)
 }
 
-type GenerateOption func(*generateOptions)
+// Refresh refreshes expired sources, see client.Client.Refresh.
+func (s *SGen) Refresh(fresh bool) error {
+	return s.client.Refresh(context.Background(), s.Sources, fresh)
+}
 
-func WithRenderer(r sgen.Renderer) GenerateOption {
-	return func(opts *generateOptions) {
-		opts.renderer = r
-	}
+// Generate renders the sources to out, see client.Client.Generate.
+func (s *SGen) Generate(out io.Writer, opts ...client.GenerateOption) error {
+	return s.client.Generate(context.Background(), out, s.Sources, opts...)
 }
 
-func WithNamedRenderer(name string) GenerateOption {
-	return func(opts *generateOptions) {
-		opts.namedRenderer = name
-	}
+// generateRequest holds the output options as they are given on the command
+// line, or to sgen serve.
+type generateRequest struct {
+	template      string
+	namedTemplate string
+	templateFile  string
+	where         string
+	sortBy        []string
+	reverse       bool
+	uniqueBy      string
+	limit         int
+	query         string
+	matchField    string
+	output        string
+	// hermetic parses template without the functions that read the
+	// environment, for templates sent to sgen serve. Their output isn't
+	// cached since every request can send a different template.
+	hermetic bool
 }
 
-func (s *SGen) Generate(out io.Writer, opts ...GenerateOption) error {
-	var options generateOptions
-	for _, opt := range opts {
-		opt(&options)
+func (r generateRequest) options() ([]client.GenerateOption, error) {
+	template := strings.TrimSpace(r.template)
+	namedTemplate := strings.TrimSpace(r.namedTemplate)
+	templateFile := strings.TrimSpace(r.templateFile)
+	set := 0
+	for _, s := range []string{template, namedTemplate, templateFile} {
+		if s != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("--template, --template-name and --template-file are mutually exclusive")
 	}
 
-	ctx := context.Background()
-	for _, src := range s.Sources {
-		rndr := options.Renderer(src)
-
-		if cache, err := s.TplCache.Get(src.Name, rndr.ID()); err == nil && cache != nil {
-			// if an error happens copying the cached date into the writer we
-			// can't just fallback to loading the underlying source and using
-			// that data since we may have partially written the cached data,
-			// which would create corrupted output on the writer
-			if _, err := io.Copy(out, bytes.NewBuffer(cache)); err != nil {
-				return err
-			}
-			continue
+	opts := []client.GenerateOption{}
+	if template != "" {
+		newRenderer := core.NewGoTemplateRenderer
+		if r.hermetic {
+			newRenderer = core.NewHermeticGoTemplateRenderer
+			opts = append(opts, client.WithoutCache())
 		}
-
-		data, err := src.Load(ctx)
-		if errors.Is(err, fs.ErrNotExist) {
-			return fmt.Errorf("generation requested for source without cached data, re-run with --sync to load data")
-		} else if err != nil {
-			return fmt.Errorf("syncing %s: %w", src.Name, err)
+		renderer, err := newRenderer(template)
+		if err != nil {
+			return nil, err
 		}
-
-		cacheW, err := s.TplCache.Open(src.Name, rndr.ID())
+		opts = append(opts, client.WithTemplate(renderer))
+	} else if namedTemplate != "" {
+		opts = append(opts, client.WithNamedRenderer(namedTemplate))
+	} else if templateFile != "" {
+		tpl, err := os.ReadFile(templateFile)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("reading --template-file: %w", err)
 		}
-		defer cacheW.Close()
-		w := io.MultiWriter(out, cacheW)
-
-		for _, datum := range data {
-			line, err := rndr.Render(datum)
-			if err != nil {
-				dataStr, err := encoding.EncodeJSONString(datum)
-				if err != nil {
-					dataStr = "<encoding JSON failure>"
-				}
-
-				return fmt.Errorf("render failure with data %q: %w", dataStr, err)
-			}
-
-			if _, err := fmt.Fprintln(w, line); err != nil {
-				return err
-			}
+		doc, err := core.NewDocumentRenderer(string(tpl))
+		if err != nil {
+			return nil, err
 		}
+		opts = append(opts, client.WithDocument(doc))
 	}
-
-	return nil
-}
-
-func (s *SGen) Sync() error {
-	ctx := context.Background()
-
-	for _, src := range s.Sources {
-		if err := s.TplCache.Clear(src.Name); err != nil {
-			return err
-		}
-
-		if err := src.Sync(ctx); err != nil {
-			return err
+	if where := strings.TrimSpace(r.where); where != "" {
+		filter, err := records.ParseFilter(where)
+		if err != nil {
+			return nil, err
 		}
+		opts = append(opts, client.WithFilter(filter))
 	}
-	return nil
-}
-
-func ToSupplier(cs *ConfigSource) (sgen.Supplier, error) {
-	type convertFn func(*ConfigSource) (sgen.Supplier, error)
-	supplierConverters := []struct {
-		Type    string
-		Convert convertFn
-	}{
-		{
-			Type: "file",
-			Convert: func(cs *ConfigSource) (sgen.Supplier, error) {
-				if cs.File == nil {
-					return nil, fmt.Errorf("%s: file sources must define a path", cs.Name)
-				}
-				return supply.NewFileSupply(cs.File.Path)
-			},
-		},
-		{
-			Type: "command",
-			Convert: func(cs *ConfigSource) (sgen.Supplier, error) {
-				if cs.Command == nil {
-					return nil, fmt.Errorf("%s: command sources must define a command", cs.Name)
-				}
-				return supply.NewCommandSupply(*cs.Command)
-			},
-		},
+	if len(r.sortBy) > 0 {
+		opts = append(opts, client.WithSort(r.sortBy, r.reverse))
+	} else if r.reverse {
+		return nil, fmt.Errorf("--reverse requires --sort-by")
 	}
-
-	var convert convertFn
-	allTypes := []string{}
-	for _, conv := range supplierConverters {
-		allTypes = append(allTypes, conv.Type)
-
-		if conv.Type == cs.Type {
-			convert = conv.Convert
-		}
+	if uniqueBy := strings.TrimSpace(r.uniqueBy); uniqueBy != "" {
+		opts = append(opts, client.WithUnique(uniqueBy))
 	}
-
-	if convert == nil {
-		validTypes := fmt.Sprintf("[%s]", strings.Join(allTypes, ","))
-		return nil, fmt.Errorf("%s: invalid source type %q, valid types are %s", cs.Name, cs.Type, validTypes)
+	if query := strings.TrimSpace(r.query); query != "" {
+		opts = append(opts, client.WithQuery(fuzzy.Parse(query), strings.TrimSpace(r.matchField)))
 	}
+	list, err := r.listRenderer()
+	if err != nil {
+		return nil, err
+	}
+	if list != nil {
+		opts = append(opts, client.WithListRenderer(list))
+	}
+	if r.limit < 0 {
+		return nil, fmt.Errorf("--limit must not be negative")
+	}
+	opts = append(opts, client.WithLimit(r.limit))
+	return opts, nil
+}
 
-	return convert(cs)
+// listRenderer returns the renderer for the output, or nil when the output is
+// a line per record.
+func (r generateRequest) listRenderer() (core.ListRenderer, error) {
+	output := strings.TrimSpace(r.output)
+	if output == "" || output == outputLines {
+		return nil, nil
+	}
+	list, err := core.NewListRenderer(output)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output %q, valid outputs are [%s,%s]", output, outputLines, strings.Join(core.ListFormats, ","))
+	}
+	return list, nil
 }
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scnewma/sgen/internal/client"
+	"github.com/scnewma/sgen/internal/hclconfig"
+)
+
+const defaultListen = "127.0.0.1:7777"
+
+func newServeCommand(g *globalOptions) *cobra.Command {
+	var (
+		listen      string
+		parallelism int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve sources over an HTTP JSON API",
+		Long: `Serve sources over an HTTP JSON API, keeping the configuration and loaded
+source data in memory between requests.
+
+Endpoints:
+  GET  /sources  list the configured sources, like sgen list --output json
+  GET  /output   render the sources named by the source query parameter, which
+                 can be repeated. template, template_name, where, sort_by,
+                 reverse, unique_by, limit, output and fresh work like their flags,
+                 query and match_field like sgen query.
+  POST /sync     sync the sources named by the source query parameter, or every
+                 source if there are none
+
+Only requests whose Host is the listen address and that have no Origin header
+are answered, and POST requests must set the X-Sgen header, i.e. X-Sgen: 1, or
+have the application/json content type. A template sent
+with a request can't use the sprig functions that read the environment, the
+clock or the network, and its output isn't cached.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := listenOn(listen)
+			if err != nil {
+				return err
+			}
+			defer l.Close()
+
+			srv, err := newServer(g.config, parallelism, l.Addr())
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "listening on %s\n", l.Addr())
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			httpSrv := &http.Server{Handler: srv}
+			errc := make(chan error, 1)
+			go func() { errc <- httpSrv.Serve(l) }()
+
+			select {
+			case err := <-errc:
+				return err
+			case <-ctx.Done():
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return httpSrv.Shutdown(shutdownCtx)
+		},
+	}
+	cmd.Flags().StringVar(&listen, "listen", defaultListen, "address to listen on, host:port or unix:PATH for a unix socket")
+	cmd.Flags().IntVar(&parallelism, "parallelism", client.DefaultParallelism, "maximum number of sources to sync at the same time")
+	return cmd
+}
+
+// listenOn listens on a TCP address or, when addr starts with "unix:", a unix
+// socket. A socket left behind by a previous server is replaced.
+func listenOn(addr string) (net.Listener, error) {
+	path, found := strings.CutPrefix(addr, "unix:")
+	if !found {
+		return net.Listen("tcp", addr)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+type server struct {
+	config      *hclconfig.Config
+	client      *client.Client
+	names       []string
+	parallelism int
+	addr        net.Addr
+	mux         *http.ServeMux
+}
+
+// newServer builds every configured source up front so requests only pay for
+// rendering. Only requests addressed to addr are served.
+func newServer(config *hclconfig.Config, parallelism int, addr net.Addr) (*server, error) {
+	c, err := client.New(
+		client.WithConfig(config),
+		client.WithMemoryCache(),
+		client.WithBackgroundRefresh(refreshInBackground),
+		client.WithDefaultParallelism(parallelism),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &server{
+		config:      config,
+		client:      c,
+		names:       c.SourceNames(),
+		parallelism: parallelism,
+		addr:        addr,
+		mux:         http.NewServeMux(),
+	}
+	for _, name := range s.names {
+		if _, err := c.Source(name); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mux.HandleFunc("GET /sources", s.handleSources)
+	s.mux.HandleFunc("GET /output", s.handleOutput)
+	s.mux.HandleFunc("POST /sync", s.handleSync)
+	return s, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.allowHost(r.Host) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed", r.Host))
+		return
+	}
+	if err := checkNotFromBrowser(r); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// checkNotFromBrowser refuses requests that a web page could have made, since
+// any page can send a POST to a local address without a CORS preflight.
+// Browsers set the Origin header on cross-origin requests, and POSTs must
+// either set the X-Sgen header or be JSON, neither of which a page can do
+// without a preflight the server never approves.
+func checkNotFromBrowser(r *http.Request) error {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return fmt.Errorf("requests from origin %q are not allowed", origin)
+	}
+	if r.Method != http.MethodPost || r.Header.Get("X-Sgen") != "" {
+		return nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		return nil
+	}
+	return errors.New("POST requests must set the X-Sgen header or have the application/json content type")
+}
+
+// allowHost reports whether a request for host is addressed to the listener,
+// so that a web page can't reach the server through a DNS name it rebinds to
+// a local address. Only the listener's IP, or localhost when it is a loopback
+// address, are allowed. Anything can be sent over a unix socket.
+func (s *server) allowHost(host string) bool {
+	addr, ok := s.addr.(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = strings.Trim(host, "[]"), "80"
+	}
+	if port != strconv.Itoa(addr.Port) {
+		return false
+	}
+	if hostname == "localhost" {
+		return addr.IP.IsLoopback() || addr.IP.IsUnspecified()
+	}
+	ip := net.ParseIP(hostname)
+	return ip != nil && (ip.Equal(addr.IP) || addr.IP.IsUnspecified())
+}
+
+// sources returns names, or every source when there are none, checking that
+// they are all configured.
+func (s *server) sources(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return s.names, nil
+	}
+	for _, name := range names {
+		if _, err := s.client.Source(name); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+func (s *server) handleSources(w http.ResponseWriter, r *http.Request) {
+	summaries := make([]sourceSummary, 0, len(s.names))
+	for _, name := range s.names {
+		summaries = append(summaries, summarizeSource(s.config, s.client, name))
+	}
+	writeJSONResponse(w, http.StatusOK, summaries)
+}
+
+func (s *server) handleOutput(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	names := query["source"]
+	if len(names) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("at least one source is required"))
+		return
+	}
+	if _, err := s.sources(names); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	req := generateRequest{
+		template:      query.Get("template"),
+		namedTemplate: query.Get("template_name"),
+		where:         query.Get("where"),
+		uniqueBy:      query.Get("unique_by"),
+		query:         query.Get("query"),
+		matchField:    query.Get("match_field"),
+		output:        query.Get("output"),
+		hermetic:      true,
+	}
+	if sortBy := query.Get("sort_by"); sortBy != "" {
+		req.sortBy = strings.Split(sortBy, ",")
+	}
+	var err error
+	var fresh bool
+	for param, dst := range map[string]*bool{"reverse": &req.reverse, "fresh": &fresh} {
+		if v := query.Get(param); v != "" {
+			if *dst, err = strconv.ParseBool(v); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid %s %q: %w", param, v, err))
+				return
+			}
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if req.limit, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q: %w", v, err))
+			return
+		}
+	}
+	opts, err := req.options()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.client.Refresh(r.Context(), names, fresh); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// output is buffered so that a failure part way through can still be
+	// reported with an error status
+	var b bytes.Buffer
+	if err := s.client.Generate(r.Context(), &b, names, opts...); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	contentType := "text/plain; charset=utf-8"
+	if list, _ := req.listRenderer(); list != nil {
+		contentType = list.ContentType()
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = b.WriteTo(w)
+}
+
+func (s *server) handleSync(w http.ResponseWriter, r *http.Request) {
+	names, err := s.sources(r.URL.Query()["source"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err := s.client.Sync(r.Context(), names); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, map[string]any{"synced": names})
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = writeJSON(w, v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSONResponse(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	core "github.com/scnewma/sgen/internal/sgen"
+)
+
+const (
+	// refreshLogName is the file in the cache directory that background
+	// refreshes write their output to, since there is no terminal to report
+	// their errors on.
+	refreshLogName = "refresh.log"
+	// maxRefreshLogSize is the size after which the refresh log is started
+	// over rather than appended to.
+	maxRefreshLogSize = 1 << 20
+)
+
+// refreshInBackground starts a detached sgen process to sync the named
+// sources. It does not wait for the process to finish.
+func refreshInBackground(names []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	log, err := openRefreshLog()
+	if err != nil {
+		return err
+	}
+	// the process has its own copy of the file once it has started
+	defer log.Close()
+	fmt.Fprintf(log, "%s refreshing %s\n", time.Now().Format(time.RFC3339), strings.Join(names, ", "))
+
+	cmd := exec.Command(exe, append([]string{"--sync-only"}, names...)...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+func openRefreshLog() (*os.File, error) {
+	dir, err := core.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, refreshLogName)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if info, err := os.Stat(path); err == nil && info.Size() > maxRefreshLogSize {
+		flags |= os.O_TRUNC
+	}
+	return os.OpenFile(path, flags, 0o644)
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scnewma/sgen/internal/hclconfig"
+)
+
+type sourceTypeSummary struct {
+	Name       string              `json:"name"`
+	Summary    string              `json:"summary"`
+	Attributes []sourceTypeAttrDoc `json:"attributes"`
+}
+
+type sourceTypeAttrDoc struct {
+	Name        string `json:"name"`
+	Required    bool   `json:"required"`
+	Block       bool   `json:"block,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func newTypesCommand(g *globalOptions) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "types",
+		Short: "List the source types and their attributes",
+		Args:  cobra.NoArgs,
+		// the built in source types and plugins on the PATH don't depend on
+		// the configuration, so they can be listed before there is one.
+		// When there is one, the plugins it sets are listed too.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if !hasConfig() {
+				return nil
+			}
+			return g.loadConfig()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutput(output); err != nil {
+				return err
+			}
+
+			config := g.config
+			if config == nil {
+				config = &hclconfig.Config{}
+			}
+			// plugins that can't be used are reported after the types that
+			// can be
+			types, pluginErr := config.SourceTypes()
+
+			summaries := []sourceTypeSummary{}
+			for _, t := range types {
+				summary := sourceTypeSummary{
+					Name:       t.Name,
+					Summary:    t.Summary,
+					Attributes: []sourceTypeAttrDoc{},
+				}
+				for _, attr := range t.Attributes() {
+					summary.Attributes = append(summary.Attributes, sourceTypeAttrDoc(attr))
+				}
+				summaries = append(summaries, summary)
+			}
+
+			var err error
+			if output == outputJSON {
+				err = writeJSON(os.Stdout, summaries)
+			} else {
+				err = writeSourceTypes(os.Stdout, summaries)
+			}
+			if err != nil {
+				return err
+			}
+			return pluginErr
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "output format, one of [text,json]")
+	return cmd
+}
+
+// hasConfig reports whether there is a configuration to load.
+func hasConfig() bool {
+	dir, err := ConfigDir()
+	if err != nil {
+		return false
+	}
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.hcl"))
+	return err == nil && len(filenames) > 0
+}
+
+// writeSourceTypes writes each type with its attributes, marking the required
+// ones, followed by the descriptions of the attributes that have one.
+// Attributes common to all types aren't repeated for each.
+func writeSourceTypes(w io.Writer, types []sourceTypeSummary) error {
+	for i, t := range types {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\n  %s\n", t.Name, t.Summary)
+		var attrs []string
+		for _, attr := range t.Attributes {
+			name := attr.Name
+			if attr.Block {
+				name += " {}"
+			}
+			if attr.Required {
+				name += " (required)"
+			}
+			attrs = append(attrs, name)
+		}
+		if _, err := fmt.Fprintf(w, "  attributes: %s\n", strings.Join(attrs, ", ")); err != nil {
+			return err
+		}
+		for _, attr := range t.Attributes {
+			if attr.Description == "" {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "    %s: %s\n", attr.Name, attr.Description); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scnewma/sgen/internal/hclconfig"
+)
+
+func newValidateCommand(g *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration, templates and sources without running them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			diags := hclconfig.Validate(g.config)
+			if err := writeDiags(diags, g.config.Files); err != nil {
+				return err
+			}
+			if len(diags) == 0 {
+				fmt.Println("Configuration is valid.")
+			}
+			return nil
+		},
+	}
+}
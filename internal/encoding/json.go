@@ -1,6 +1,11 @@
 package encoding
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
 
 func EncodeJSON(v any) ([]byte, error) {
 	return json.Marshal(v)
@@ -13,3 +18,28 @@ func EncodeJSONString(v any) (string, error) {
 	}
 	return string(buf), nil
 }
+
+// DecodeJSON decodes buf into v like json.Unmarshal, except that numbers
+// decoded into an interface are json.Numbers rather than float64s, so that
+// integers such as IDs keep every digit and aren't rendered in exponent form.
+func DecodeJSON(buf []byte, v any) error {
+	dec := NewJSONDecoder(bytes.NewReader(buf))
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
+// NewJSONDecoder returns a decoder reading from r that decodes numbers as
+// json.Numbers, see DecodeJSON.
+func NewJSONDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
@@ -0,0 +1,38 @@
+// Package fieldpath resolves dotted paths, i.e. "owner.login" or "items.0.id",
+// against decoded JSON data.
+package fieldpath
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Lookup returns the value found by walking path through v. Each segment of
+// the path indexes an object by key or an array by position. An empty path
+// returns v itself.
+func Lookup(v any, path string) (any, bool) {
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return v, true
+	}
+
+	for _, seg := range strings.Split(path, ".") {
+		switch vv := v.(type) {
+		case map[string]any:
+			next, found := vv[seg]
+			if !found {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(vv) {
+				return nil, false
+			}
+			v = vv[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
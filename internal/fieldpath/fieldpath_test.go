@@ -0,0 +1,45 @@
+package fieldpath
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLookup(t *testing.T) {
+	data := map[string]any{
+		"name":  "sgen",
+		"owner": map[string]any{"login": "scnewma"},
+		"items": []any{
+			map[string]any{"id": float64(1)},
+			map[string]any{"id": float64(2)},
+		},
+	}
+
+	tests := []struct {
+		path   string
+		expect any
+		found  bool
+	}{
+		{path: "", expect: data, found: true},
+		{path: "name", expect: "sgen", found: true},
+		{path: ".name", expect: "sgen", found: true},
+		{path: "owner.login", expect: "scnewma", found: true},
+		{path: "items.1.id", expect: float64(2), found: true},
+		{path: "items.2.id", found: false},
+		{path: "owner.missing", found: false},
+		{path: "name.nested", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, found := Lookup(data, tt.path)
+			if found != tt.found {
+				t.Fatalf("Lookup(%q) found = %v, want %v", tt.path, found, tt.found)
+			}
+			if diff := cmp.Diff(tt.expect, got); diff != "" {
+				t.Errorf("Lookup(%q) mismatch (-want +got):\n%s", tt.path, diff)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/scnewma/sgen/internal/encoding"
 )
 
 func Exists(path string) bool {
@@ -25,7 +27,71 @@ func WriteJSON(path string, v any) error {
 	if err != nil {
 		return fmt.Errorf("encoding json data: %w", err)
 	}
-	return os.WriteFile(path, buf, 0755)
+	return WriteFileAtomic(path, buf, 0755)
+}
+
+// WriteFileAtomic writes data to a temporary file and renames it to path, so
+// readers see either the old contents of path or all of data, never a
+// partial write.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := CreateAtomic(path, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// AtomicFile is a file that replaces path once it's committed. Until then it
+// is written to a temporary file in the same directory.
+type AtomicFile struct {
+	*os.File
+	path      string
+	perm      os.FileMode
+	committed bool
+}
+
+// CreateAtomic creates a temporary file that replaces path when committed.
+// The caller must always Close the file, which removes the temporary file if
+// it was not committed.
+func CreateAtomic(path string, perm os.FileMode) (*AtomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: f, path: path, perm: perm}, nil
+}
+
+// Commit flushes the file to disk and renames it to its final path.
+func (f *AtomicFile) Commit() error {
+	if err := f.File.Chmod(f.perm); err != nil {
+		return err
+	}
+	if err := f.File.Sync(); err != nil {
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.File.Name(), f.path); err != nil {
+		return err
+	}
+	f.committed = true
+	return nil
+}
+
+// Close discards the file if it was not committed.
+func (f *AtomicFile) Close() error {
+	if f.committed {
+		return nil
+	}
+	// the file may already be closed by a failed commit
+	_ = f.File.Close()
+	return os.Remove(f.File.Name())
 }
 
 func ReadJSON(path string, v any) error {
@@ -33,5 +99,5 @@ func ReadJSON(path string, v any) error {
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(buf, v)
+	return encoding.DecodeJSON(buf, v)
 }
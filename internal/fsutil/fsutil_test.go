@@ -0,0 +1,70 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFileAtomic(path, []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic() error: %v", err)
+	}
+	assertContents(t, path, "new")
+	assertOnlyFile(t, dir, "out")
+}
+
+func TestAtomicFileDiscardedWithoutCommit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := CreateAtomic(path, 0644)
+	if err != nil {
+		t.Fatalf("CreateAtomic() error: %v", err)
+	}
+	if _, err := f.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	assertContents(t, path, "old")
+	assertOnlyFile(t, dir, "out")
+}
+
+func assertContents(t *testing.T, path, expect string) {
+	t.Helper()
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expect {
+		t.Errorf("expected %q, got %q", expect, buf)
+	}
+}
+
+// assertOnlyFile checks that no temporary files were left behind.
+func assertOnlyFile(t *testing.T, dir, name string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != name {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("expected only %q in directory, got %v", name, names)
+	}
+}
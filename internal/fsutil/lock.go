@@ -0,0 +1,48 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Lock is an advisory lock on a file, held until Unlock is called or the
+// process exits.
+type Lock struct {
+	f *os.File
+}
+
+// LockShared blocks until a shared lock on path is acquired. Any number of
+// processes may hold a shared lock at the same time, but not while another
+// holds an exclusive lock.
+func LockShared(path string) (*Lock, error) {
+	return lock(path, false)
+}
+
+// LockExclusive blocks until an exclusive lock on path is acquired.
+func LockExclusive(path string) (*Lock, error) {
+	return lock(path, true)
+}
+
+func lock(path string, exclusive bool) (*Lock, error) {
+	if err := EnsureDirExists(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f, exclusive); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Lock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	if err := funlock(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
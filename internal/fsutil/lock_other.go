@@ -0,0 +1,16 @@
+//go:build !unix
+
+package fsutil
+
+import "os"
+
+// file locking is only supported on unix, elsewhere concurrent syncs rely on
+// atomic writes alone
+
+func flock(*os.File, bool) error {
+	return nil
+}
+
+func funlock(*os.File) error {
+	return nil
+}
@@ -0,0 +1,162 @@
+// Package fuzzy ranks text against fuzzy search terms, in the style of fzf.
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scores are the same as fzf's, so results are ranked the way people used to
+// piping sgen through fzf expect
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	// bonusBoundary is given to characters at the start of a word, i.e. after
+	// a space, slash or dash
+	bonusBoundary = scoreMatch / 2
+	// bonusCamel is given to upper case characters following lower case ones
+	// and digits following letters
+	bonusCamel = bonusBoundary - 1
+	// bonusConsecutive is given to each character matched straight after the
+	// previous one
+	bonusConsecutive = -(scoreGapStart + scoreGapExtension)
+	// bonusFirstCharMultiplier weights the bonus of the first character of a
+	// term, since that is usually the start of what's being looked for
+	bonusFirstCharMultiplier = 2
+)
+
+// Query is a set of search terms. Text must match every term, in any order.
+type Query struct {
+	raw   string
+	terms [][]rune
+}
+
+// Parse splits s into whitespace separated terms. Terms are matched case
+// insensitively unless they contain an upper case character.
+func Parse(s string) *Query {
+	q := &Query{raw: s}
+	for _, term := range strings.Fields(s) {
+		q.terms = append(q.terms, []rune(term))
+	}
+	return q
+}
+
+// String returns the query the terms were parsed from.
+func (q *Query) String() string {
+	return q.raw
+}
+
+// Score reports whether text matches every term of the query, and how well.
+// Higher scores are better matches. A query without terms matches everything
+// with a score of zero.
+func (q *Query) Score(text string) (int, bool) {
+	runes := []rune(text)
+	total := 0
+	for _, term := range q.terms {
+		score, ok := scoreTerm(term, runes)
+		if !ok {
+			return 0, false
+		}
+		total += score
+	}
+	return total, true
+}
+
+// scoreTerm finds the characters of term, in order, in text and scores the
+// shortest window they were found in.
+func scoreTerm(term, text []rune) (int, bool) {
+	caseSensitive := false
+	for _, r := range term {
+		if unicode.IsUpper(r) {
+			caseSensitive = true
+			break
+		}
+	}
+	equal := func(a, b rune) bool {
+		if caseSensitive {
+			return a == b
+		}
+		return unicode.ToLower(a) == unicode.ToLower(b)
+	}
+
+	// find where the first occurrence of the term as a subsequence ends
+	end, t := -1, 0
+	for i := 0; i < len(text) && t < len(term); i++ {
+		if equal(text[i], term[t]) {
+			t++
+			if t == len(term) {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return 0, false
+	}
+
+	// then work backwards from there to find the latest start, which gives
+	// the tightest match
+	start, t := end, len(term)-1
+	for i := end; i >= 0; i-- {
+		if equal(text[i], term[t]) {
+			start = i
+			t--
+			if t < 0 {
+				break
+			}
+		}
+	}
+
+	score, t := 0, 0
+	inGap, consecutive := false, false
+	chunkBonus := 0
+	for i := start; i <= end; i++ {
+		if t >= len(term) || !equal(text[i], term[t]) {
+			if inGap {
+				score += scoreGapExtension
+			} else {
+				score += scoreGapStart
+			}
+			inGap, consecutive = true, false
+			continue
+		}
+
+		b := bonus(text, i)
+		if consecutive {
+			// a run of consecutive characters keeps the bonus of the
+			// character that started it
+			b = max(b, chunkBonus, bonusConsecutive)
+		} else {
+			chunkBonus = b
+		}
+		if t == 0 {
+			b *= bonusFirstCharMultiplier
+		}
+		score += scoreMatch + b
+		inGap, consecutive = false, true
+		t++
+	}
+	return score, true
+}
+
+// bonus returns the bonus for matching the character of text at i, based on
+// the character before it.
+func bonus(text []rune, i int) int {
+	if i == 0 {
+		return bonusBoundary
+	}
+	prev, cur := text[i-1], text[i]
+	switch {
+	case !isWord(prev) && isWord(cur):
+		return bonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur),
+		unicode.IsLetter(prev) && unicode.IsDigit(cur):
+		return bonusCamel
+	}
+	return 0
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,82 @@
+package fuzzy
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScoreMatches(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		match bool
+	}{
+		{query: "sgen", text: "scnewma/sgen", match: true},
+		{query: "sn", text: "scnewma/sgen", match: true},
+		{query: "SGEN", text: "scnewma/sgen", match: false},
+		{query: "Sgen", text: "scnewma/Sgen", match: true},
+		{query: "gens", text: "scnewma/sgen", match: false},
+		{query: "sgen scnewma", text: "scnewma/sgen", match: true},
+		{query: "sgen hashicorp", text: "scnewma/sgen", match: false},
+		{query: "", text: "anything", match: true},
+		{query: "ü", text: "Müller", match: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query+" in "+tt.text, func(t *testing.T) {
+			_, match := Parse(tt.query).Score(tt.text)
+			if match != tt.match {
+				t.Errorf("Score() match = %t, want %t", match, tt.match)
+			}
+		})
+	}
+}
+
+func TestScoreRanking(t *testing.T) {
+	tests := []struct {
+		query  string
+		texts  []string
+		expect []string
+	}{
+		{
+			query:  "sg",
+			texts:  []string{"messaging", "some-gateway", "sgen"},
+			expect: []string{"sgen", "some-gateway", "messaging"},
+		},
+		{
+			query: "api",
+			texts: []string{"rapid", "web-api", "a-p-i"},
+			// word starts outweigh the gaps between them
+			expect: []string{"web-api", "a-p-i", "rapid"},
+		},
+		{
+			query:  "hc",
+			texts:  []string{"hashicorp", "HashiCorp"},
+			expect: []string{"HashiCorp", "hashicorp"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q := Parse(tt.query)
+			scores := map[string]int{}
+			for _, text := range tt.texts {
+				score, ok := q.Score(text)
+				if !ok {
+					t.Fatalf("expected %q to match %q", text, tt.query)
+				}
+				scores[text] = score
+			}
+
+			ranked := append([]string(nil), tt.texts...)
+			sort.SliceStable(ranked, func(i, j int) bool {
+				return scores[ranked[i]] > scores[ranked[j]]
+			})
+			if diff := cmp.Diff(tt.expect, ranked); diff != "" {
+				t.Errorf("ranking mismatch (-want +got):\n%s\nscores: %v", diff, scores)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package hclconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "default"},
+		{Name: "description"},
+	},
+}
+
+// scope holds the values that expressions in the config can reference, aside
+// from those that depend on the file the expression is in.
+type scope struct {
+	variables map[string]cty.Value
+	locals    map[string]cty.Value
+}
+
+// evalContext returns the context to evaluate expressions defined in
+// filename in.
+func (s *scope) evalContext(filename string) *hcl.EvalContext {
+	dir := filepath.Dir(filename)
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"sgen": cty.ObjectVal(map[string]cty.Value{
+				// directory where the filename is located
+				"directory": cty.StringVal(dir),
+			}),
+			"var":   cty.ObjectVal(s.variables),
+			"local": cty.ObjectVal(s.locals),
+		},
+		Functions: functions(dir),
+	}
+}
+
+// decodeVariables sets the value of every variable block, preferring values
+// given by the user over the block's default.
+func (s *scope) decodeVariables(files []*configFile, values map[string]string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	declared := map[string]hcl.Range{}
+	for _, f := range files {
+		// variables can't reference other variables or locals, but they can
+		// use functions
+		ctx := (&scope{}).evalContext(f.name)
+
+		for _, block := range f.variables {
+			name := block.Labels[0]
+			if prev, found := declared[name]; found {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate variable",
+					Detail:   fmt.Sprintf("A variable named %q was already declared at %s. Variable names must be unique.", name, prev),
+					Subject:  block.DefRange.Ptr(),
+				})
+				continue
+			}
+			declared[name] = block.DefRange
+
+			content, moreDiags := block.Body.Content(variableSchema)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				s.variables[name] = cty.DynamicVal
+				continue
+			}
+
+			def := cty.NullVal(cty.DynamicPseudoType)
+			if attr, found := content.Attributes["default"]; found {
+				def, moreDiags = attr.Expr.Value(ctx)
+				diags = append(diags, moreDiags...)
+			}
+
+			raw, set := values[name]
+			switch {
+			case set:
+				val, err := variableValue(raw, def)
+				if err != nil {
+					diags = append(diags, &hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  "Invalid value for variable",
+						Detail:   fmt.Sprintf("The value %q for variable %q is invalid: %s.", raw, name, err),
+						Subject:  block.DefRange.Ptr(),
+					})
+					val = cty.DynamicVal
+				}
+				s.variables[name] = val
+			case !def.IsNull():
+				s.variables[name] = def
+			default:
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "No value for required variable",
+					Detail: fmt.Sprintf(
+						"The variable %q has no default, so a value must be set with --var %s=VALUE or the SGEN_VAR_%s environment variable.",
+						name, name, name,
+					),
+					Subject: block.DefRange.Ptr(),
+				})
+				s.variables[name] = cty.DynamicVal
+			}
+		}
+	}
+	return diags
+}
+
+// variableValue converts a value given on the command line or in the
+// environment to the type of the variable's default, so that i.e. a variable
+// with a default of 10 stays a number.
+func variableValue(raw string, def cty.Value) (cty.Value, error) {
+	val := cty.StringVal(raw)
+	if def.IsNull() || !def.Type().IsPrimitiveType() {
+		return val, nil
+	}
+	return convert.Convert(val, def.Type())
+}
+
+type local struct {
+	filename string
+	attr     *hcl.Attribute
+}
+
+// decodeLocals evaluates every local value. Locals may reference each other
+// in any order, so they are evaluated once all of the locals they reference
+// have been.
+func (s *scope) decodeLocals(files []*configFile) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	defined := map[string]*local{}
+	var pending []*local
+	for _, f := range files {
+		for _, block := range f.locals {
+			attrs, moreDiags := block.Body.JustAttributes()
+			diags = append(diags, moreDiags...)
+
+			// attributes are a map, sort them so diagnostics are stable
+			names := make([]string, 0, len(attrs))
+			for name := range attrs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				attr := attrs[name]
+				if prev, found := defined[name]; found {
+					diags = append(diags, &hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  "Duplicate local value",
+						Detail:   fmt.Sprintf("A local value named %q was already defined at %s. Local value names must be unique.", name, prev.attr.NameRange),
+						Subject:  attr.NameRange.Ptr(),
+					})
+					continue
+				}
+				l := &local{filename: f.name, attr: attr}
+				defined[name] = l
+				pending = append(pending, l)
+			}
+		}
+	}
+
+	for len(pending) > 0 {
+		var unresolved []*local
+		for _, l := range pending {
+			if !s.localReady(l, defined) {
+				unresolved = append(unresolved, l)
+				continue
+			}
+			val, moreDiags := l.attr.Expr.Value(s.evalContext(l.filename))
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				val = cty.DynamicVal
+			}
+			s.locals[l.attr.Name] = val
+		}
+
+		if len(unresolved) == len(pending) {
+			for _, l := range unresolved {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Unresolvable local value",
+					Detail:   fmt.Sprintf("The local value %q depends on itself, either directly or through other local values.", l.attr.Name),
+					Subject:  l.attr.Expr.Range().Ptr(),
+				})
+				s.locals[l.attr.Name] = cty.DynamicVal
+			}
+			break
+		}
+		pending = unresolved
+	}
+	return diags
+}
+
+// localReady reports whether every local value that l references has been
+// evaluated. References to locals that aren't defined are left for
+// evaluation to report.
+func (s *scope) localReady(l *local, defined map[string]*local) bool {
+	for _, traversal := range l.attr.Expr.Variables() {
+		if traversal.RootName() != "local" || len(traversal) < 2 {
+			continue
+		}
+		attr, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok {
+			continue
+		}
+		if _, isDefined := defined[attr.Name]; !isDefined {
+			continue
+		}
+		if _, resolved := s.locals[attr.Name]; !resolved {
+			return false
+		}
+	}
+	return true
+}
+
+// functions returns the functions available in the config. Relative paths
+// given to file() are relative to dir, the directory of the file the
+// function is called in.
+func functions(dir string) map[string]function.Function {
+	return map[string]function.Function{
+		"coalesce":     stdlib.CoalesceFunc,
+		"concat":       stdlib.ConcatFunc,
+		"contains":     stdlib.ContainsFunc,
+		"env":          envFunc,
+		"file":         makeFileFunc(dir),
+		"format":       stdlib.FormatFunc,
+		"formatlist":   stdlib.FormatListFunc,
+		"homedir":      homedirFunc,
+		"join":         stdlib.JoinFunc,
+		"jsondecode":   stdlib.JSONDecodeFunc,
+		"jsonencode":   stdlib.JSONEncodeFunc,
+		"keys":         stdlib.KeysFunc,
+		"length":       stdlib.LengthFunc,
+		"lookup":       stdlib.LookupFunc,
+		"lower":        stdlib.LowerFunc,
+		"merge":        stdlib.MergeFunc,
+		"regex":        stdlib.RegexFunc,
+		"regexreplace": stdlib.RegexReplaceFunc,
+		"replace":      stdlib.ReplaceFunc,
+		"split":        stdlib.SplitFunc,
+		"title":        stdlib.TitleFunc,
+		"trimprefix":   stdlib.TrimPrefixFunc,
+		"trimspace":    stdlib.TrimSpaceFunc,
+		"trimsuffix":   stdlib.TrimSuffixFunc,
+		"upper":        stdlib.UpperFunc,
+		"values":       stdlib.ValuesFunc,
+	}
+}
+
+// envFunc returns the value of an environment variable, or the optional
+// second argument if it is unset or empty.
+var envFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "name", Type: cty.String},
+	},
+	VarParam: &function.Parameter{Name: "default", Type: cty.String},
+	Type:     function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if len(args) > 2 {
+			return cty.NilVal, fmt.Errorf("env takes at most 2 arguments, a name and a default")
+		}
+		if v := os.Getenv(args[0].AsString()); v != "" {
+			return cty.StringVal(v), nil
+		}
+		if len(args) == 2 {
+			return args[1], nil
+		}
+		return cty.StringVal(""), nil
+	},
+})
+
+var homedirFunc = function.New(&function.Spec{
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return cty.NilVal, err
+		}
+		return cty.StringVal(home), nil
+	},
+})
+
+func makeFileFunc(dir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path := args[0].AsString()
+			if rest, found := strings.CutPrefix(path, "~/"); found {
+				home, err := os.UserHomeDir()
+				if err != nil {
+					return cty.NilVal, err
+				}
+				path = filepath.Join(home, rest)
+			}
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+
+			buf, err := os.ReadFile(path)
+			if err != nil {
+				return cty.NilVal, fmt.Errorf("reading %s: %w", path, err)
+			}
+			return cty.StringVal(string(buf)), nil
+		},
+	})
+}
@@ -0,0 +1,131 @@
+package hclconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// configFile is a parsed configuration file and the blocks it defines.
+type configFile struct {
+	name      string
+	sources   []*hcl.Block
+	variables []*hcl.Block
+	locals    []*hcl.Block
+	plugins   []*hcl.Block
+	// override files may redefine sources from other files
+	override bool
+}
+
+// loader parses configuration files, following their include blocks.
+type loader struct {
+	parser    *hclparse.Parser
+	files     []*configFile
+	overrides []*configFile
+	// seen are the absolute paths of the files that have been loaded, so that
+	// a file included more than once (or in a cycle) is only loaded once
+	seen map[string]bool
+}
+
+func newLoader() *loader {
+	return &loader{
+		parser: hclparse.NewParser(),
+		seen:   make(map[string]bool),
+	}
+}
+
+func (l *loader) load(filename string) hcl.Diagnostics {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		abs = filename
+	}
+	if l.seen[abs] {
+		return nil
+	}
+	l.seen[abs] = true
+
+	f, diags := l.parser.ParseHCLFile(filename)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	content, moreDiags := f.Body.Content(configSchema)
+	diags = append(diags, moreDiags...)
+
+	cf := &configFile{name: filename, override: isOverrideFile(filename)}
+	if cf.override {
+		l.overrides = append(l.overrides, cf)
+	} else {
+		l.files = append(l.files, cf)
+	}
+
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "source":
+			cf.sources = append(cf.sources, block)
+		case "variable":
+			cf.variables = append(cf.variables, block)
+		case "locals":
+			cf.locals = append(cf.locals, block)
+		case "plugin":
+			cf.plugins = append(cf.plugins, block)
+		case "include":
+			filenames, moreDiags := resolveInclude(filename, block)
+			diags = append(diags, moreDiags...)
+			for _, included := range filenames {
+				diags = append(diags, l.load(included)...)
+			}
+		}
+	}
+	return diags
+}
+
+// resolveInclude returns the files matched by an include block. The path is
+// relative to the directory of the file the block is in and may be a glob or
+// a directory, in which case every *.hcl file in the directory is included.
+func resolveInclude(from string, block *hcl.Block) ([]string, hcl.Diagnostics) {
+	pattern := block.Labels[0]
+	if rest, found := strings.CutPrefix(pattern, "~/"); found {
+		if home, err := os.UserHomeDir(); err == nil {
+			pattern = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(from), pattern)
+	}
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		pattern = filepath.Join(pattern, "*.hcl")
+	}
+
+	filenames, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid include",
+			Detail:   fmt.Sprintf("The include path %q is not a valid glob: %s.", block.Labels[0], err),
+			Subject:  block.LabelRanges[0].Ptr(),
+		}}
+	}
+	// a glob that doesn't match anything is fine, but a specific file that
+	// doesn't exist is almost certainly a mistake
+	if len(filenames) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Included file not found",
+			Detail:   fmt.Sprintf("The included file %s does not exist.", pattern),
+			Subject:  block.LabelRanges[0].Ptr(),
+		}}
+	}
+	return filenames, nil
+}
+
+// isOverrideFile reports whether filename is an override file, like
+// Terraform, named override.hcl or ending in _override.hcl.
+func isOverrideFile(filename string) bool {
+	base := filepath.Base(filename)
+	return base == "override.hcl" || strings.HasSuffix(base, "_override.hcl")
+}
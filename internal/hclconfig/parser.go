@@ -3,10 +3,14 @@ package hclconfig
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
-	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/scnewma/sgen/internal/selector"
 	"github.com/scnewma/sgen/internal/sgen"
 	"github.com/scnewma/sgen/internal/sgen/supply"
 	"github.com/zclconf/go-cty/cty"
@@ -16,24 +20,118 @@ const DefaultTemplateName = "default"
 
 type Config struct {
 	Sources map[string]Source
-	Files   map[string]*hcl.File
+	// Variables are the values of every declared variable by name.
+	Variables map[string]cty.Value
+	Files     map[string]*hcl.File
+
+	// plugins are the source types supplied by plugins, set when the config
+	// is parsed
+	plugins *pluginTypes
+}
+
+// LookupSourceType returns the built in source type name, or the type
+// supplied by a plugin.
+func (c *Config) LookupSourceType(name string) (SourceType, bool) {
+	if st, found := LookupSourceType(name); found {
+		return st, true
+	}
+	if c.plugins == nil {
+		return SourceType{}, false
+	}
+	st, found, err := c.plugins.lookup(name)
+	return st, found && err == nil
+}
+
+// SourceTypes returns the built in source types followed by the types
+// supplied by plugins, either set with plugin blocks or on the PATH. Plugins
+// that can't be used are returned as errors along with the other types.
+func (c *Config) SourceTypes() ([]SourceType, error) {
+	plugins := c.plugins
+	if plugins == nil {
+		plugins = newPluginTypes()
+	}
+	sts, err := plugins.types()
+	return append(SourceTypes(), sts...), err
 }
 
 type Source interface {
 	GetName() string
+	GetType() string
+	GetDeclRange() hcl.Range
+	GetSrcRange() hcl.Range
 	GetTemplates() map[string]string
-	ToSupplier() (sgen.Supplier, error)
+	// GetTemplateMode returns how the named template is rendered, either
+	// TemplateModeRecord or TemplateModeDocument.
+	GetTemplateMode(name string) string
+	GetTTL() time.Duration
+	GetSortBy() []string
+	// GetAlfred returns the templates for rendering the source's records as
+	// list items, or nil if the source doesn't have an alfred block.
+	GetAlfred() *AlfredBlock
+	// GetInputs returns the names of the sources this source is derived
+	// from.
+	GetInputs() []string
+	// ToSupplier builds the source's supplier. inputs are the sources named
+	// by GetInputs.
+	ToSupplier(inputs map[string]*sgen.Source) (sgen.Supplier, error)
+	// Validate checks the source for problems that would only otherwise be
+	// found when it is used, without running it.
+	Validate() hcl.Diagnostics
 }
 
+const (
+	// TemplateModeRecord templates are rendered once for each record, which
+	// is the default.
+	TemplateModeRecord = "record"
+	// TemplateModeDocument templates are rendered once with all of the
+	// records, see sgen.Document.
+	TemplateModeDocument = "document"
+)
+
 type SourceBlock struct {
-	Name      string
+	Name string
+	Type string
+	// DeclRange is the range of the block's header, i.e. source "file" "x".
+	DeclRange hcl.Range
+	// SrcRange is the range of the entire block.
+	SrcRange  hcl.Range
 	Templates map[string]string
+	// TemplateModes are the modes of the templates that set one, by template
+	// name.
+	TemplateModes map[string]string
+	TTL           time.Duration
+	SortBy        []string
+	// Select picks the records out of the source's output, see
+	// selector.Selector.
+	Select string
+	Alfred *AlfredBlock
+
+	// AttrRanges are the ranges of the block's attribute expressions by
+	// attribute name and TemplateRanges are the ranges of the template
+	// values by template name. They are used to point diagnostics at the
+	// exact expression that has a problem.
+	AttrRanges     map[string]hcl.Range
+	TemplateRanges map[string]hcl.Range
+	// AlfredRange is the range of the alfred block's header.
+	AlfredRange hcl.Range
 }
 
 func (b *SourceBlock) GetName() string {
 	return b.Name
 }
 
+func (b *SourceBlock) GetType() string {
+	return b.Type
+}
+
+func (b *SourceBlock) GetDeclRange() hcl.Range {
+	return b.DeclRange
+}
+
+func (b *SourceBlock) GetSrcRange() hcl.Range {
+	return b.SrcRange
+}
+
 func (b *SourceBlock) GetNamedTemplate(name string) (string, bool) {
 	t, ok := b.Templates[name]
 	return t, ok
@@ -43,130 +141,436 @@ func (b *SourceBlock) GetTemplates() map[string]string {
 	return b.Templates
 }
 
-type FileSourceBlock struct {
-	SourceBlock
-	Path string
+func (b *SourceBlock) GetTemplateMode(name string) string {
+	if mode, found := b.TemplateModes[name]; found {
+		return mode
+	}
+	return TemplateModeRecord
+}
+
+func (b *SourceBlock) GetTTL() time.Duration {
+	return b.TTL
+}
+
+func (b *SourceBlock) GetSortBy() []string {
+	return b.SortBy
+}
+
+func (b *SourceBlock) GetAlfred() *AlfredBlock {
+	return b.Alfred
 }
 
-func (b *FileSourceBlock) ToSupplier() (sgen.Supplier, error) {
-	return supply.NewFileSupply(b.Path)
+func (b *SourceBlock) GetInputs() []string {
+	return nil
 }
 
-type CommandSourceBlock struct {
-	SourceBlock
-	Command string
+// AlfredBlock holds go templates for the fields of the items output by
+// --output alfred, raycast, rofi and dmenu.
+type AlfredBlock struct {
+	UID          string `hcl:"uid,optional"`
+	Title        string `hcl:"title,optional"`
+	Subtitle     string `hcl:"subtitle,optional"`
+	Arg          string `hcl:"arg,optional"`
+	Autocomplete string `hcl:"autocomplete,optional"`
+	Icon         string `hcl:"icon,optional"`
 }
 
-func (b *CommandSourceBlock) ToSupplier() (sgen.Supplier, error) {
-	return supply.NewCommandSupply(b.Command)
+// ItemTemplates converts the block to the templates of an sgen.ItemRenderer.
+func (b *AlfredBlock) ItemTemplates() sgen.ItemTemplates {
+	return sgen.ItemTemplates{
+		UID:          b.UID,
+		Title:        b.Title,
+		Subtitle:     b.Subtitle,
+		Arg:          b.Arg,
+		Autocomplete: b.Autocomplete,
+		Icon:         b.Icon,
+	}
 }
 
 var configSchema = &hcl.BodySchema{
 	Blocks: []hcl.BlockHeaderSchema{
 		{Type: "source", LabelNames: []string{"type", "name"}},
+		{Type: "include", LabelNames: []string{"path"}},
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "locals"},
+		{Type: "plugin", LabelNames: []string{"type"}},
 	},
 }
 
-func Parse(filename string) (*Config, hcl.Diagnostics) {
+type options struct {
+	variables map[string]string
+	cacheDir  string
+}
+
+type Option func(*options)
+
+// WithVariables sets the values of variables declared in the configuration,
+// i.e. from --var flags. Values are converted to the type of the variable's
+// default.
+func WithVariables(vars map[string]string) Option {
+	return func(o *options) {
+		o.variables = vars
+	}
+}
+
+// WithCacheDir caches the schemas of plugins in dir rather than the default
+// cache directory, see readPluginSchema.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cacheDir = dir
+	}
+}
+
+// Parse loads the configuration in filename and any files it includes.
+func Parse(filename string, opts ...Option) (*Config, hcl.Diagnostics) {
+	return parse([]string{filename}, opts...)
+}
+
+// ParseDir loads the configuration in every *.hcl file in dir and any files
+// they include.
+func ParseDir(dir string, opts ...Option) (*Config, hcl.Diagnostics) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.hcl"))
+	if err == nil && len(filenames) == 0 {
+		err = fmt.Errorf("no *.hcl files found")
+	}
+	if err != nil {
+		config := &Config{
+			Sources:   make(map[string]Source),
+			Variables: make(map[string]cty.Value),
+			Files:     make(map[string]*hcl.File),
+		}
+		return config, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read configuration directory",
+			Detail:   fmt.Sprintf("The configuration directory %s could not be read: %s.", dir, err),
+		}}
+	}
+	return parse(filenames, opts...)
+}
+
+func parse(filenames []string, opts ...Option) (*Config, hcl.Diagnostics) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	var diags hcl.Diagnostics
 	config := &Config{
 		Sources: make(map[string]Source),
-		Files:   make(map[string]*hcl.File),
 	}
 
-	parser := hclparse.NewParser()
-	f, moreDiags := parser.ParseHCLFile(filename)
-	diags = append(diags, moreDiags...)
-	if moreDiags.HasErrors() {
-		return config, diags
+	l := newLoader()
+	for _, filename := range filenames {
+		diags = append(diags, l.load(filename)...)
 	}
+	config.Files = l.parser.Files()
 
-	context := &hcl.EvalContext{
-		Variables: map[string]cty.Value{
-			"sgen": cty.ObjectVal(map[string]cty.Value{
-				// directory where the filename is located
-				"directory": cty.StringVal(filepath.Dir(filename)),
-			}),
-		},
+	// override files are decoded last so that they can replace sources
+	// defined in any other file
+	files := append(l.files, l.overrides...)
+
+	s := &scope{
+		variables: make(map[string]cty.Value),
+		locals:    make(map[string]cty.Value),
+	}
+	diags = append(diags, s.decodeVariables(files, o.variables)...)
+	diags = append(diags, s.decodeLocals(files)...)
+	config.Variables = s.variables
+
+	// plugins are set before any sources are decoded, since sources in any
+	// file can use them
+	plugins := newPluginTypes()
+	plugins.cacheDir = o.cacheDir
+	config.plugins = plugins
+	for _, f := range files {
+		context := s.evalContext(f.name)
+		for _, block := range f.plugins {
+			diags = append(diags, plugins.decodeBlock(context, f.name, f.override, block)...)
+		}
+	}
+
+	for _, f := range files {
+		context := s.evalContext(f.name)
+
+		for _, block := range f.sources {
+			source, moreDiags := decodeSource(context, block, plugins)
+			diags = append(diags, moreDiags...)
+			if source == nil || moreDiags.HasErrors() {
+				continue
+			}
+
+			if prev, found := config.Sources[source.GetName()]; found && !f.override {
+				diags = append(diags, duplicateSourceDiags(prev, source)...)
+				continue
+			}
+			config.Sources[source.GetName()] = source
+		}
 	}
 
-	config.Files[filename] = f
-
-	content, moreDiags := f.Body.Content(configSchema)
-	diags = append(diags, moreDiags...)
-	for _, block := range content.Blocks {
-		switch block.Type {
-		case "source":
-			typ := block.Labels[0]
-			name := block.Labels[1]
-			switch typ {
-			case "file":
-				source, moreDiags := decodeFileSource(name, context, block)
-				diags = append(diags, moreDiags...)
-				if moreDiags.HasErrors() {
-					continue
-				}
-				config.Sources[source.Name] = source
-			case "command":
-				source, moreDiags := decodeCommandSource(name, context, block)
-				diags = append(diags, moreDiags...)
-				if moreDiags.HasErrors() {
-					continue
-				}
-				config.Sources[source.Name] = source
-			default:
+	diags = append(diags, checkInputs(config.Sources)...)
+	return config, diags
+}
+
+// checkInputs reports sources that are derived from sources that don't exist
+// or, through other sources, from themselves.
+func checkInputs(sources map[string]Source) hcl.Diagnostics {
+	var names []string
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diags hcl.Diagnostics
+	for _, name := range names {
+		source := sources[name]
+		for _, input := range source.GetInputs() {
+			if _, found := sources[input]; !found {
 				diags = append(diags, &hcl.Diagnostic{
 					Severity: hcl.DiagError,
-					Summary:  fmt.Sprintf("Source type %q unknown", typ),
+					Summary:  "Unknown source",
+					Detail:   fmt.Sprintf("Source %q is derived from the source %q, which is not defined.", name, input),
+					Subject:  source.GetDeclRange().Ptr(),
 				})
 			}
 		}
 	}
-	return config, diags
+	if diags.HasErrors() {
+		return diags
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var visit func(name string, path []string) *hcl.Diagnostic
+	visit = func(name string, path []string) *hcl.Diagnostic {
+		path = append(path, name)
+		switch state[name] {
+		case visiting:
+			source := sources[path[0]]
+			return &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Circular source reference",
+				Detail:   fmt.Sprintf("Sources can't be derived from themselves: %s.", strings.Join(path, " -> ")),
+				Subject:  source.GetDeclRange().Ptr(),
+			}
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		defer func() { state[name] = visited }()
+		for _, input := range sources[name].GetInputs() {
+			// each cycle is only reported once, from the first source in it
+			if diag := visit(input, path); diag != nil {
+				return diag
+			}
+		}
+		return nil
+	}
+	for _, name := range names {
+		if diag := visit(name, nil); diag != nil {
+			diags = append(diags, diag)
+		}
+	}
+	return diags
 }
 
-func decodeFileSource(name string, context *hcl.EvalContext, block *hcl.Block) (*FileSourceBlock, hcl.Diagnostics) {
-	source := &FileSourceBlock{
-		SourceBlock: SourceBlock{Name: name},
+// decodeSource decodes a source block with its registered type, see
+// SourceType, or with the plugin for its type.
+func decodeSource(context *hcl.EvalContext, block *hcl.Block, plugins *pluginTypes) (Source, hcl.Diagnostics) {
+	typ := block.Labels[0]
+	name := block.Labels[1]
+	st, found := LookupSourceType(typ)
+	if !found {
+		var err error
+		st, found, err = plugins.lookup(typ)
+		if err != nil {
+			return nil, hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid plugin",
+				Detail:   fmt.Sprintf("The plugin for source type %q could not be used: %s.", typ, err),
+				Subject:  block.LabelRanges[0].Ptr(),
+			}}
+		}
 	}
-	var b struct {
-		Path      string `hcl:"path"`
-		Templates []struct {
-			Name  string `hcl:"name"`
-			Value string `hcl:"value"`
-		} `hcl:"template,block"`
+	if !found {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("Source type %q unknown", typ),
+			Detail: fmt.Sprintf(
+				"Valid source types are %s. Other types are supplied by a plugin, either named %s%s on the PATH or set with a plugin block.",
+				strings.Join(SourceTypeNames(), ", "), supply.PluginPrefix, typ,
+			),
+			Subject: block.LabelRanges[0].Ptr(),
+		}}
 	}
-	diags := gohcl.DecodeBody(block.Body, context, &b)
+
+	sb, body, diags := decodeSourceBlock(name, context, block)
 	if diags.HasErrors() {
-		return source, diags
+		return nil, diags
 	}
-	source.Templates = make(map[string]string)
-	for _, tpl := range b.Templates {
-		source.Templates[tpl.Name] = tpl.Value
+	source, moreDiags := st.Decode(sb, body, context, block)
+	return source, append(diags, moreDiags...)
+}
+
+// duplicateSourceDiags reports a source that is defined more than once, with
+// a diagnostic pointing at each definition.
+func duplicateSourceDiags(first, second Source) hcl.Diagnostics {
+	name := first.GetName()
+	firstRng, secondRng := first.GetDeclRange(), second.GetDeclRange()
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Duplicate source",
+			Detail: fmt.Sprintf(
+				"A source named %q was already defined at %s. Source names must be unique, to replace a source redefine it in an override file (override.hcl or *_override.hcl).",
+				name, firstRng,
+			),
+			Subject: &secondRng,
+		},
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Duplicate source",
+			Detail:   fmt.Sprintf("The source %q is defined here and again at %s.", name, secondRng),
+			Subject:  &firstRng,
+		},
 	}
-	source.Path = b.Path
-	return source, diags
 }
 
-func decodeCommandSource(name string, context *hcl.EvalContext, block *hcl.Block) (*CommandSourceBlock, hcl.Diagnostics) {
-	source := &CommandSourceBlock{
-		SourceBlock: SourceBlock{Name: name},
+// decodeSourceBlock decodes the properties common to all source types and
+// returns the remaining body for the type specific properties.
+func decodeSourceBlock(name string, context *hcl.EvalContext, block *hcl.Block) (SourceBlock, hcl.Body, hcl.Diagnostics) {
+	source := SourceBlock{
+		Name:      name,
+		Type:      block.Labels[0],
+		DeclRange: block.DefRange,
+		SrcRange:  block.DefRange,
+	}
+	if body, ok := block.Body.(*hclsyntax.Body); ok {
+		source.SrcRange = hcl.RangeBetween(block.DefRange, body.SrcRange)
 	}
 	var b struct {
-		Command   string `hcl:"command"`
+		TTL       *string  `hcl:"ttl,optional"`
+		SortBy    []string `hcl:"sort_by,optional"`
+		Select    string   `hcl:"select,optional"`
 		Templates []struct {
 			Name  string `hcl:"name"`
 			Value string `hcl:"value"`
+			Mode  string `hcl:"mode,optional"`
 		} `hcl:"template,block"`
+		Alfred *AlfredBlock `hcl:"alfred,block"`
+		Remain hcl.Body     `hcl:",remain"`
 	}
 	diags := gohcl.DecodeBody(block.Body, context, &b)
 	if diags.HasErrors() {
-		return source, diags
+		return source, b.Remain, diags
+	}
+	source.SortBy = b.SortBy
+	source.Select = b.Select
+	source.Alfred = b.Alfred
+	source.AlfredRange = block.DefRange
+
+	source.AttrRanges = make(map[string]hcl.Range)
+	source.TemplateRanges = make(map[string]hcl.Range)
+	// the ranges of the template modes by the template's index
+	modeRanges := make(map[int]hcl.Range)
+	if body, ok := block.Body.(*hclsyntax.Body); ok {
+		for name, attr := range body.Attributes {
+			source.AttrRanges[name] = attr.Expr.Range()
+		}
+		// template blocks are decoded in the order they are defined
+		i := 0
+		for _, tplBlock := range body.Blocks {
+			if tplBlock.Type == "alfred" {
+				source.AlfredRange = tplBlock.DefRange()
+			}
+			if tplBlock.Type != "template" || i >= len(b.Templates) {
+				continue
+			}
+			if attr, found := tplBlock.Body.Attributes["value"]; found {
+				source.TemplateRanges[b.Templates[i].Name] = attr.Expr.Range()
+			}
+			if attr, found := tplBlock.Body.Attributes["mode"]; found {
+				modeRanges[i] = attr.Expr.Range()
+			}
+			i++
+		}
 	}
-	source.Command = b.Command
 	source.Templates = make(map[string]string)
-	for _, tpl := range b.Templates {
+	for i, tpl := range b.Templates {
 		source.Templates[tpl.Name] = tpl.Value
+		switch tpl.Mode {
+		case "":
+		case TemplateModeRecord, TemplateModeDocument:
+			if source.TemplateModes == nil {
+				source.TemplateModes = make(map[string]string)
+			}
+			source.TemplateModes[tpl.Name] = tpl.Mode
+		default:
+			rng, found := modeRanges[i]
+			if !found {
+				rng = block.DefRange
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid template mode",
+				Detail:   fmt.Sprintf("The mode %q of template %q must be %q or %q.", tpl.Mode, tpl.Name, TemplateModeRecord, TemplateModeDocument),
+				Subject:  &rng,
+			})
+		}
+	}
+	if b.TTL != nil {
+		ttl, err := time.ParseDuration(*b.TTL)
+		if err != nil || ttl <= 0 {
+			rng, found := source.AttrRanges["ttl"]
+			if !found {
+				rng = block.DefRange
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid ttl",
+				Detail:   fmt.Sprintf("The ttl %q must be a positive duration, i.e. \"30m\" or \"6h\".", *b.TTL),
+				Subject:  &rng,
+			})
+		}
+		source.TTL = ttl
+	}
+	if b.Select != "" {
+		if _, err := selector.Parse(b.Select); err != nil {
+			rng, found := source.AttrRanges["select"]
+			if !found {
+				rng = block.DefRange
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid select",
+				Detail:   fmt.Sprintf("The select expression is invalid: %s.", err),
+				Subject:  &rng,
+			})
+		}
+	}
+	return source, b.Remain, diags
+}
+
+// decodeTimeout parses the timeout attribute of a source, zero if it isn't
+// set.
+func decodeTimeout(sb SourceBlock, timeout *string, block *hcl.Block) (time.Duration, hcl.Diagnostics) {
+	if timeout == nil {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(*timeout)
+	if err != nil || d <= 0 {
+		rng, found := sb.AttrRanges["timeout"]
+		if !found {
+			rng = block.DefRange
+		}
+		return 0, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid timeout",
+			Detail:   fmt.Sprintf("The timeout %q must be a positive duration, i.e. \"30s\" or \"2m\".", *timeout),
+			Subject:  &rng,
+		}}
 	}
-	return source, diags
+	return d, nil
 }
@@ -1,24 +1,68 @@
 package hclconfig
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestParse(t *testing.T) {
+	noHeader := false
+	noInherit := false
 	expect := &Config{
 		Sources: map[string]Source{
 			"gh": &CommandSourceBlock{
 				SourceBlock: SourceBlock{
 					Name:      "gh",
+					Type:      "command",
 					Templates: map[string]string{},
+					TTL:       6 * time.Hour,
 				},
 				Command: "gh repo list --json nameWithOwner",
 			},
+			"gh_enterprise": &CommandSourceBlock{
+				SourceBlock: SourceBlock{
+					Name:      "gh_enterprise",
+					Type:      "command",
+					Templates: map[string]string{},
+				},
+				Command:    "gh repo list --json nameWithOwner",
+				Env:        map[string]string{"GH_HOST": "github.example.com"},
+				InheritEnv: &noInherit,
+				WorkingDir: "/tmp",
+				StdinFile:  "/dev/null",
+				Timeout:    30 * time.Second,
+			},
+			"gh_names": &CommandSourceBlock{
+				SourceBlock: SourceBlock{
+					Name:      "gh_names",
+					Type:      "command",
+					Templates: map[string]string{},
+				},
+				Args: []string{"gh", "api", "-q", ".[] | {name}", "/orgs/scnewma/repos"},
+			},
+			"gh_bash": &CommandSourceBlock{
+				SourceBlock: SourceBlock{
+					Name:      "gh_bash",
+					Type:      "command",
+					Templates: map[string]string{},
+				},
+				Command: "!gh repo list --json nameWithOwner | jq -c '.[]'",
+				Shell:   "bash",
+				Format:  "jsonl",
+			},
 			"gh_w_template": &CommandSourceBlock{
 				SourceBlock: SourceBlock{
 					Name: "gh_w_template",
+					Type: "command",
 					Templates: map[string]string{
 						"default": "{{.nameWithOwner}}",
 						"name":    "{{.name}}",
@@ -29,13 +73,16 @@ func TestParse(t *testing.T) {
 			"static": &FileSourceBlock{
 				SourceBlock: SourceBlock{
 					Name:      "static",
+					Type:      "file",
 					Templates: map[string]string{},
+					SortBy:    []string{"name", "owner.login"},
 				},
 				Path: "/data.json",
 			},
 			"static_w_template": &FileSourceBlock{
 				SourceBlock: SourceBlock{
 					Name: "static_w_template",
+					Type: "file",
 					Templates: map[string]string{
 						"default": "{{.nameWithOwner}}",
 						"name":    "{{.name}}",
@@ -43,6 +90,49 @@ func TestParse(t *testing.T) {
 				},
 				Path: "/data.json",
 			},
+			"inventory": &FileSourceBlock{
+				SourceBlock: SourceBlock{
+					Name:      "inventory",
+					Type:      "file",
+					Templates: map[string]string{},
+				},
+				Path:      "/inventory.txt",
+				Format:    "csv",
+				Delimiter: ";",
+				HasHeader: &noHeader,
+				Columns:   []string{"host", "env"},
+			},
+			"api": &HTTPSourceBlock{
+				SourceBlock: SourceBlock{
+					Name: "api",
+					Type: "http",
+					Templates: map[string]string{
+						"default": "{{.full_name}}",
+					},
+				},
+				URL:            "https://api.github.com/orgs/scnewma/repos",
+				Headers:        map[string]string{"Accept": "application/vnd.github+json"},
+				BearerTokenEnv: "GITHUB_TOKEN",
+				Pagination:     &HTTPPagination{Type: "link"},
+				Timeout:        10 * time.Second,
+			},
+			"api_cursor": &HTTPSourceBlock{
+				SourceBlock: SourceBlock{
+					Name:      "api_cursor",
+					Type:      "http",
+					Templates: map[string]string{},
+				},
+				URL:    "https://example.com/api/items",
+				Method: "POST",
+				Body:   "{}",
+				Items:  "data.items",
+				Pagination: &HTTPPagination{
+					Type:        "cursor",
+					CursorPath:  "meta.next",
+					CursorParam: "after",
+					MaxPages:    10,
+				},
+			},
 		},
 	}
 
@@ -51,7 +141,450 @@ func TestParse(t *testing.T) {
 		t.Fatalf("Unexpected diagnostics: %s", diags)
 	}
 
-	if diff := cmp.Diff(expect.Sources, parsed.Sources); diff != "" {
+	// ranges depend on the exact layout of the testdata, which isn't what
+	// this test is about
+	ignoreRanges := cmp.Options{
+		cmpopts.IgnoreTypes(hcl.Range{}),
+		cmpopts.IgnoreFields(SourceBlock{}, "AttrRanges", "TemplateRanges"),
+	}
+	if diff := cmp.Diff(expect.Sources, parsed.Sources, ignoreRanges); diff != "" {
 		t.Errorf("Parse() data mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestParseInvalidTTL(t *testing.T) {
+	_, diags := Parse("testdata/invalid_ttl.hcl")
+	if !diags.HasErrors() {
+		t.Fatal("expected diagnostics for invalid ttl")
+	}
+	if got := diags[0].Summary; got != "Invalid ttl" {
+		t.Errorf("unexpected diagnostic summary %q", got)
+	}
+	if got := diags[0].Subject.Start.Line; got != 3 {
+		t.Errorf("expected diagnostic on line 3, got %d", got)
+	}
+}
+
+func TestParseInvalidTimeout(t *testing.T) {
+	_, diags := Parse("testdata/invalid_timeout.hcl")
+	if !diags.HasErrors() {
+		t.Fatal("expected diagnostics for invalid timeout")
+	}
+	if got := diags[0].Summary; got != "Invalid timeout" {
+		t.Errorf("unexpected diagnostic summary %q", got)
+	}
+	if got := diags[0].Subject.Start.Line; got != 3 {
+		t.Errorf("expected diagnostic on line 3, got %d", got)
+	}
+}
+
+func TestParseInvalidCommand(t *testing.T) {
+	_, diags := Parse("testdata/invalid_command.hcl")
+	if !diags.HasErrors() {
+		t.Fatal("expected diagnostics for invalid command")
+	}
+	var summaries []string
+	for _, diag := range diags {
+		summaries = append(summaries, diag.Summary)
+	}
+	if diff := cmp.Diff([]string{"Invalid command", "Invalid command"}, summaries); diff != "" {
+		t.Errorf("unexpected diagnostics (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseInvalidSelect(t *testing.T) {
+	_, diags := Parse("testdata/invalid_select.hcl")
+	if !diags.HasErrors() {
+		t.Fatal("expected diagnostics for invalid select")
+	}
+	if got := diags[0].Summary; got != "Invalid select" {
+		t.Errorf("unexpected diagnostic summary %q", got)
+	}
+	if got := diags[0].Subject.Start.Line; got != 3 {
+		t.Errorf("expected diagnostic on line 3, got %d", got)
+	}
+}
+
+func TestParseInvalidTemplateMode(t *testing.T) {
+	_, diags := Parse("testdata/invalid_template_mode.hcl")
+	if !diags.HasErrors() {
+		t.Fatal("expected diagnostics for invalid template mode")
+	}
+	if got := diags[0].Summary; got != "Invalid template mode" {
+		t.Errorf("unexpected diagnostic summary %q", got)
+	}
+	// the diagnostic points at the mode rather than the source block
+	if got := diags[0].Subject.Start.Line; got != 6 {
+		t.Errorf("expected diagnostic on line 6, got %d", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	config, diags := Parse("testdata/invalid.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("Unexpected diagnostics: %s", diags)
+	}
+
+	expect := []struct {
+		summary string
+		line    int
+	}{
+		{summary: "Command not found", line: 2},
+		{summary: "Invalid template", line: 10},
+		{summary: "File not found", line: 15},
+		{summary: "Invalid template", line: 21},
+	}
+
+	diags = Validate(config)
+	if len(diags) != len(expect) {
+		t.Fatalf("expected %d diagnostics, got %d: %s", len(expect), len(diags), diags)
+	}
+	for i, e := range expect {
+		if diags[i].Summary != e.summary {
+			t.Errorf("diagnostic %d: expected summary %q, got %q", i, e.summary, diags[i].Summary)
+		}
+		if diags[i].Subject == nil || diags[i].Subject.Start.Line != e.line {
+			t.Errorf("diagnostic %d: expected subject on line %d, got %v", i, e.line, diags[i].Subject)
+		}
+	}
+}
+
+func TestParseDir(t *testing.T) {
+	config, diags := ParseDir("testdata/multi")
+	if diags.HasErrors() {
+		t.Fatalf("Unexpected diagnostics: %s", diags)
+	}
+
+	expect := map[string]string{
+		"b": "echo b",
+		"c": "echo c",
+		"d": "echo local d",
+	}
+	if len(config.Sources) != len(expect)+1 {
+		t.Errorf("expected %d sources, got %d", len(expect)+1, len(config.Sources))
+	}
+	for name, command := range expect {
+		source, ok := config.Sources[name].(*CommandSourceBlock)
+		if !ok {
+			t.Errorf("source %q missing or not a command source", name)
+			continue
+		}
+		if source.Command != command {
+			t.Errorf("source %q: expected command %q, got %q", name, command, source.Command)
+		}
+	}
+
+	// sgen.directory is the directory of the file the source is defined in
+	file, ok := config.Sources["a"].(*FileSourceBlock)
+	if !ok {
+		t.Fatalf("source \"a\" missing or not a file source")
+	}
+	if file.Path != "testdata/multi/a.json" {
+		t.Errorf("unexpected path %q", file.Path)
+	}
+}
+
+func TestParseDirDuplicateSources(t *testing.T) {
+	_, diags := ParseDir("testdata/duplicate")
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %s", len(diags), diags)
+	}
+
+	// one diagnostic points at each definition
+	files := map[string]bool{}
+	for _, diag := range diags {
+		if diag.Summary != "Duplicate source" {
+			t.Errorf("unexpected diagnostic summary %q", diag.Summary)
+		}
+		files[diag.Subject.Filename] = true
+	}
+	if !files["testdata/duplicate/a.hcl"] || !files["testdata/duplicate/b.hcl"] {
+		t.Errorf("expected diagnostics to point at both files, got %v", files)
+	}
+}
+
+func TestParseVariables(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+
+	config, diags := Parse("testdata/variables.hcl", WithVariables(map[string]string{
+		"org":   "SCNewma",
+		"limit": "5",
+	}))
+	if diags.HasErrors() {
+		t.Fatalf("Unexpected diagnostics: %s", diags)
+	}
+
+	// values given as strings take the type of the variable's default
+	if got := config.Variables["limit"]; !got.RawEquals(cty.NumberIntVal(5)) {
+		t.Errorf("expected limit to be the number 5, got %#v", got)
+	}
+
+	expect := map[string]string{
+		"repos": "gh repo list scnewma --limit 5",
+		"env":   "echo fallback",
+	}
+	for name, command := range expect {
+		source, ok := config.Sources[name].(*CommandSourceBlock)
+		if !ok {
+			t.Errorf("source %q missing or not a command source", name)
+			continue
+		}
+		if source.Command != command {
+			t.Errorf("source %q: expected command %q, got %q", name, command, source.Command)
+		}
+	}
+
+	file, ok := config.Sources["home"].(*FileSourceBlock)
+	if !ok {
+		t.Fatalf("source \"home\" missing or not a file source")
+	}
+	if want := home + "/data.json"; file.Path != want {
+		t.Errorf("expected path %q, got %q", want, file.Path)
+	}
+}
+
+func TestParseInvalidVariables(t *testing.T) {
+	_, diags := Parse("testdata/invalid_locals.hcl")
+
+	expect := []string{
+		"No value for required variable",
+		"Unresolvable local value",
+		"Unresolvable local value",
+	}
+	if len(diags) != len(expect) {
+		t.Fatalf("expected %d diagnostics, got %d: %s", len(expect), len(diags), diags)
+	}
+	for i, summary := range expect {
+		if diags[i].Summary != summary {
+			t.Errorf("diagnostic %d: expected summary %q, got %q", i, summary, diags[i].Summary)
+		}
+	}
+}
+
+func TestParseJoin(t *testing.T) {
+	config, diags := Parse("testdata/join.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("Unexpected diagnostics: %s", diags)
+	}
+
+	join, ok := config.Sources["repo-owners"].(*JoinSourceBlock)
+	if !ok {
+		t.Fatalf("expected a join source, got %T", config.Sources["repo-owners"])
+	}
+	if diff := cmp.Diff([]string{"repos", "users"}, join.GetInputs()); diff != "" {
+		t.Errorf("GetInputs() mismatch (-want +got):\n%s", diff)
+	}
+	if join.LeftKey != "owner.login" || join.RightKey != "login" || join.JoinType != "left" || join.RightPrefix != "owner_" {
+		t.Errorf("unexpected join %+v", join)
+	}
+}
+
+func TestParseInvalidJoin(t *testing.T) {
+	tests := []struct {
+		path    string
+		summary string
+		detail  string
+	}{
+		{
+			path:    "testdata/invalid_join.hcl",
+			summary: "Unknown source",
+			detail:  `Source "repo-owners" is derived from the source "users", which is not defined.`,
+		},
+		{
+			path:    "testdata/circular_join.hcl",
+			summary: "Circular source reference",
+			detail:  "Sources can't be derived from themselves: a -> b -> a.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, diags := Parse(tt.path)
+			if len(diags) != 1 {
+				t.Fatalf("expected 1 diagnostic, got %d: %s", len(diags), diags)
+			}
+			if diags[0].Summary != tt.summary {
+				t.Errorf("expected summary %q, got %q", tt.summary, diags[0].Summary)
+			}
+			if diags[0].Detail != tt.detail {
+				t.Errorf("expected detail %q, got %q", tt.detail, diags[0].Detail)
+			}
+		})
+	}
+}
+
+func TestParseUnion(t *testing.T) {
+	config, diags := Parse("testdata/union.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("Unexpected diagnostics: %s", diags)
+	}
+
+	union, ok := config.Sources["all"].(*UnionSourceBlock)
+	if !ok {
+		t.Fatalf("expected a union source, got %T", config.Sources["all"])
+	}
+	if diff := cmp.Diff([]string{"work", "personal"}, union.GetInputs()); diff != "" {
+		t.Errorf("GetInputs() mismatch (-want +got):\n%s", diff)
+	}
+	if union.SourceField != "origin" {
+		t.Errorf("expected source field %q, got %q", "origin", union.SourceField)
+	}
+}
+
+func TestParsePlugin(t *testing.T) {
+	plugins, err := filepath.Abs("testdata/plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tickets type is found on the PATH, the jira type is set with a
+	// plugin block
+	t.Setenv("PATH", plugins+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("SGEN_CACHE_DIR", t.TempDir())
+
+	config, diags := Parse("testdata/plugin.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("Unexpected diagnostics: %s", diags)
+	}
+
+	expect := map[string]*PluginSourceBlock{
+		"sgen": {
+			Plugin: filepath.Join("testdata", "plugins", "sgen-supplier-tickets"),
+			Attributes: map[string]json.RawMessage{
+				"project": json.RawMessage(`"SGEN"`),
+				"labels":  json.RawMessage(`["bug","docs"]`),
+				"limit":   json.RawMessage(`50`),
+			},
+		},
+		"all": {
+			Plugin: filepath.Join(plugins, "sgen-supplier-tickets"),
+			Attributes: map[string]json.RawMessage{
+				"project": json.RawMessage(`"ALL"`),
+			},
+		},
+	}
+	for name, e := range expect {
+		source, ok := config.Sources[name].(*PluginSourceBlock)
+		if !ok {
+			t.Errorf("source %q missing or not a plugin source", name)
+			continue
+		}
+		if source.Plugin != e.Plugin {
+			t.Errorf("source %q: expected plugin %q, got %q", name, e.Plugin, source.Plugin)
+		}
+		if source.Cache {
+			t.Errorf("source %q: expected the plugin's schema to disable caching", name)
+		}
+		if diff := cmp.Diff(e.Attributes, source.Attributes); diff != "" {
+			t.Errorf("source %q attributes mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+}
+
+func TestPluginSourceTypes(t *testing.T) {
+	plugins, err := filepath.Abs("testdata/plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", plugins+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("SGEN_CACHE_DIR", t.TempDir())
+
+	config, diags := Parse("testdata/plugin.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("Unexpected diagnostics: %s", diags)
+	}
+	types, err := config.SourceTypes()
+	if err != nil {
+		t.Fatalf("SourceTypes() error: %v", err)
+	}
+	var names []string
+	for _, st := range types {
+		names = append(names, st.Name)
+	}
+	expect := append(SourceTypeNames(), "jira", "tickets")
+	if diff := cmp.Diff(expect, names); diff != "" {
+		t.Errorf("SourceTypes() mismatch (-want +got):\n%s", diff)
+	}
+
+	st, found := config.LookupSourceType("jira")
+	if !found {
+		t.Fatal("expected the jira plugin's source type to be found")
+	}
+	expectAttrs := []SourceTypeAttribute{
+		{Name: "labels"},
+		{Name: "limit"},
+		{Name: "project", Required: true, Description: "The key of the project."},
+	}
+	if diff := cmp.Diff(expectAttrs, st.Attributes()); diff != "" {
+		t.Errorf("Attributes() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPluginSchemaCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	plugin := filepath.Join(t.TempDir(), "plugin")
+	writePlugin := func(summary string, modTime time.Time) {
+		t.Helper()
+		script := fmt.Sprintf("#!/bin/sh\necho '{\"summary\": %q, \"attributes\": []}'\n", summary)
+		if err := os.WriteFile(plugin, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(plugin, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summary := func() string {
+		t.Helper()
+		schema, err := readPluginSchema(plugin, cacheDir)
+		if err != nil {
+			t.Fatalf("readPluginSchema() error: %v", err)
+		}
+		return schema.Summary
+	}
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writePlugin("First", modTime)
+	if got := summary(); got != "First" {
+		t.Errorf("expected the plugin's schema, got summary %q", got)
+	}
+
+	// the cached schema is used while the plugin's modification time and
+	// size are unchanged
+	writePlugin("Later", modTime)
+	if got := summary(); got != "First" {
+		t.Errorf("expected the cached schema, got summary %q", got)
+	}
+
+	writePlugin("Later", modTime.Add(time.Minute))
+	if got := summary(); got != "Later" {
+		t.Errorf("expected the changed plugin's schema, got summary %q", got)
+	}
+}
+
+func TestParseInvalidPlugin(t *testing.T) {
+	t.Setenv("SGEN_CACHE_DIR", t.TempDir())
+	_, diags := Parse("testdata/invalid_plugin.hcl")
+
+	expect := []struct {
+		summary string
+		line    int
+	}{
+		{summary: "Built in source type", line: 19},
+		{summary: "Missing required argument", line: 5},
+		{summary: "Incorrect attribute value type", line: 11},
+		{summary: "Unsupported argument", line: 16},
+	}
+	if len(diags) != len(expect) {
+		t.Fatalf("expected %d diagnostics, got %d: %s", len(expect), len(diags), diags)
+	}
+	for i, e := range expect {
+		if diags[i].Summary != e.summary {
+			t.Errorf("diagnostic %d: expected summary %q, got %q", i, e.summary, diags[i].Summary)
+		}
+		if diags[i].Subject == nil || diags[i].Subject.Start.Line != e.line {
+			t.Errorf("diagnostic %d: expected subject on line %d, got %v", i, e.line, diags[i].Subject)
+		}
+	}
+}
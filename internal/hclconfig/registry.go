@@ -0,0 +1,129 @@
+package hclconfig
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+)
+
+// SourceType is a type of source, i.e. the "file" of source "file" "name".
+// It declares the attributes specific to the type and how they are decoded.
+// The decoded Source builds the type's supplier, see Source.ToSupplier, and
+// validates it.
+type SourceType struct {
+	Name string
+	// Summary describes the type in a sentence, for sgen types and describe.
+	Summary string
+	// Schema returns the attributes and blocks specific to the type.
+	Schema func() *hcl.BodySchema
+	// Descriptions describe the attributes in Schema by name, for sgen
+	// types. Not every attribute has one.
+	Descriptions map[string]string
+	// Decode decodes the source's body, less the common properties, into a
+	// Source with the common properties sb. block is the source block, for
+	// the ranges of diagnostics.
+	Decode func(sb SourceBlock, body hcl.Body, context *hcl.EvalContext, block *hcl.Block) (Source, hcl.Diagnostics)
+}
+
+// SourceTypeAttribute is an attribute, or block, specific to a source type.
+type SourceTypeAttribute struct {
+	Name        string
+	Required    bool
+	Block       bool
+	Description string
+}
+
+// Attributes returns the type's attributes and blocks, as declared by its
+// Schema, sorted by name.
+func (t SourceType) Attributes() []SourceTypeAttribute {
+	schema := t.Schema()
+	var attrs []SourceTypeAttribute
+	for _, attr := range schema.Attributes {
+		attrs = append(attrs, SourceTypeAttribute{Name: attr.Name, Required: attr.Required, Description: t.Descriptions[attr.Name]})
+	}
+	for _, block := range schema.Blocks {
+		attrs = append(attrs, SourceTypeAttribute{Name: block.Type, Block: true})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Name < attrs[j].Name
+	})
+	return attrs
+}
+
+// newSourceType declares a source type whose attributes are decoded into a
+// T, a struct with hcl tags.
+func newSourceType[T any](name, summary string, decode func(sb SourceBlock, attrs *T, block *hcl.Block) (Source, hcl.Diagnostics)) SourceType {
+	return SourceType{
+		Name:    name,
+		Summary: summary,
+		Schema: func() *hcl.BodySchema {
+			schema, _ := gohcl.ImpliedBodySchema(new(T))
+			return schema
+		},
+		Decode: func(sb SourceBlock, body hcl.Body, context *hcl.EvalContext, block *hcl.Block) (Source, hcl.Diagnostics) {
+			attrs := new(T)
+			diags := gohcl.DecodeBody(body, context, attrs)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			source, moreDiags := decode(sb, attrs, block)
+			return source, append(diags, moreDiags...)
+		},
+	}
+}
+
+var (
+	sourceTypesMu sync.RWMutex
+	sourceTypes   = map[string]SourceType{
+		fileSourceType.Name:    fileSourceType,
+		commandSourceType.Name: commandSourceType,
+		httpSourceType.Name:    httpSourceType,
+		joinSourceType.Name:    joinSourceType,
+		unionSourceType.Name:   unionSourceType,
+	}
+)
+
+// RegisterSourceType makes a source type available to configurations. It
+// panics if a type with the same name is already registered.
+func RegisterSourceType(t SourceType) {
+	sourceTypesMu.Lock()
+	defer sourceTypesMu.Unlock()
+	if _, found := sourceTypes[t.Name]; found {
+		panic(fmt.Sprintf("hclconfig: source type %q registered twice", t.Name))
+	}
+	sourceTypes[t.Name] = t
+}
+
+// LookupSourceType returns the registered source type called name.
+func LookupSourceType(name string) (SourceType, bool) {
+	sourceTypesMu.RLock()
+	defer sourceTypesMu.RUnlock()
+	t, found := sourceTypes[name]
+	return t, found
+}
+
+// SourceTypes returns every registered source type, sorted by name.
+func SourceTypes() []SourceType {
+	sourceTypesMu.RLock()
+	defer sourceTypesMu.RUnlock()
+	types := make([]SourceType, 0, len(sourceTypes))
+	for _, t := range sourceTypes {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Name < types[j].Name
+	})
+	return types
+}
+
+// SourceTypeNames returns the names of every registered source type, sorted.
+func SourceTypeNames() []string {
+	var names []string
+	for _, t := range SourceTypes() {
+		names = append(names, t.Name)
+	}
+	return names
+}
@@ -0,0 +1,79 @@
+package hclconfig
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/scnewma/sgen/internal/sgen"
+)
+
+// staticSourceBlock is a source type registered by the tests, with records
+// given in the configuration.
+type staticSourceBlock struct {
+	SourceBlock
+	Values []string
+}
+
+type staticSupplier []string
+
+func (s staticSupplier) ShouldCache() bool { return false }
+
+func (s staticSupplier) Supply(context.Context) ([]map[string]any, error) {
+	var data []map[string]any
+	for _, v := range s {
+		data = append(data, map[string]any{"value": v})
+	}
+	return data, nil
+}
+
+func (b *staticSourceBlock) ToSupplier(map[string]*sgen.Source) (sgen.Supplier, error) {
+	return staticSupplier(b.Values), nil
+}
+
+func TestRegisterSourceType(t *testing.T) {
+	type staticAttributes struct {
+		Values []string `hcl:"values"`
+	}
+	RegisterSourceType(newSourceType("static", "Records listed in the configuration.", func(sb SourceBlock, attrs *staticAttributes, _ *hcl.Block) (Source, hcl.Diagnostics) {
+		return &staticSourceBlock{SourceBlock: sb, Values: attrs.Values}, nil
+	}))
+	t.Cleanup(func() {
+		sourceTypesMu.Lock()
+		defer sourceTypesMu.Unlock()
+		delete(sourceTypes, "static")
+	})
+
+	config, diags := Parse("testdata/custom_type.hcl")
+	if len(diags) != 1 || diags[0].Summary != `Source type "shell" unknown` {
+		t.Fatalf("expected only the unknown type to be reported, got: %s", diags)
+	}
+	if !strings.Contains(diags[0].Detail, "command, file, http, join, static, union") {
+		t.Errorf("expected the valid types in the detail, got %q", diags[0].Detail)
+	}
+
+	source, ok := config.Sources["colors"].(*staticSourceBlock)
+	if !ok {
+		t.Fatalf("source colors missing or not a static source: %#v", config.Sources["colors"])
+	}
+	if diff := cmp.Diff([]string{"red", "green"}, source.Values); diff != "" {
+		t.Errorf("decoded values mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSourceTypeAttributes(t *testing.T) {
+	st, found := LookupSourceType("union")
+	if !found {
+		t.Fatal("union source type not registered")
+	}
+	expect := []SourceTypeAttribute{
+		{Name: "source_field"},
+		{Name: "sources", Required: true},
+	}
+	if diff := cmp.Diff(expect, st.Attributes()); diff != "" {
+		t.Errorf("Attributes() mismatch (-want +got):\n%s", diff)
+	}
+}
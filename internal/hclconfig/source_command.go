@@ -0,0 +1,140 @@
+package hclconfig
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/scnewma/sgen/internal/fsutil"
+	"github.com/scnewma/sgen/internal/sgen"
+	"github.com/scnewma/sgen/internal/sgen/supply"
+)
+
+var commandSourceType = newSourceType("command", "Runs a command and reads records from its stdout.", decodeCommandSource)
+
+type CommandSourceBlock struct {
+	SourceBlock
+	Command    string
+	Args       []string
+	Shell      string
+	Format     string
+	Env        map[string]string
+	InheritEnv *bool
+	WorkingDir string
+	Stdin      string
+	StdinFile  string
+	Timeout    time.Duration
+}
+
+type commandAttributes struct {
+	Command    string            `hcl:"command,optional"`
+	Args       []string          `hcl:"args,optional"`
+	Shell      string            `hcl:"shell,optional"`
+	Format     string            `hcl:"format,optional"`
+	Env        map[string]string `hcl:"env,optional"`
+	InheritEnv *bool             `hcl:"inherit_env,optional"`
+	WorkingDir string            `hcl:"working_dir,optional"`
+	Stdin      string            `hcl:"stdin,optional"`
+	StdinFile  string            `hcl:"stdin_file,optional"`
+	Timeout    *string           `hcl:"timeout,optional"`
+}
+
+func decodeCommandSource(sb SourceBlock, attrs *commandAttributes, block *hcl.Block) (Source, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	source := &CommandSourceBlock{
+		SourceBlock: sb,
+		Command:     attrs.Command,
+		Args:        attrs.Args,
+		Shell:       attrs.Shell,
+		Format:      attrs.Format,
+		Env:         attrs.Env,
+		InheritEnv:  attrs.InheritEnv,
+		WorkingDir:  attrs.WorkingDir,
+		Stdin:       attrs.Stdin,
+		StdinFile:   attrs.StdinFile,
+	}
+	if (attrs.Command == "") == (len(attrs.Args) == 0) {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid command",
+			Detail:   fmt.Sprintf("Exactly one of command and args must be set for source %q.", sb.Name),
+			Subject:  block.DefRange.Ptr(),
+		})
+	}
+	timeout, moreDiags := decodeTimeout(sb, attrs.Timeout, block)
+	source.Timeout = timeout
+	diags = append(diags, moreDiags...)
+	return source, diags
+}
+
+func (b *CommandSourceBlock) ToSupplier(map[string]*sgen.Source) (sgen.Supplier, error) {
+	return supply.NewCommandSupply(b.Command, supply.CommandOptions{
+		Args:       b.Args,
+		Shell:      b.Shell,
+		Format:     b.Format,
+		Select:     b.Select,
+		Env:        b.Env,
+		InheritEnv: b.InheritEnv,
+		WorkingDir: b.WorkingDir,
+		Stdin:      b.Stdin,
+		StdinFile:  b.StdinFile,
+		Timeout:    b.Timeout,
+	})
+}
+
+// Validate checks that the command can be found on the PATH, and that its
+// working directory and stdin file exist, in addition to the common checks.
+func (b *CommandSourceBlock) Validate() hcl.Diagnostics {
+	diags := b.SourceBlock.Validate()
+	rng := b.attrRange("command")
+	if b.Command == "" && len(b.Args) > 0 {
+		rng = b.attrRange("args")
+	}
+
+	// the command is checked on its own first so that problems with it point
+	// at the command attribute
+	cmd, err := supply.NewCommandSupply(b.Command, supply.CommandOptions{Args: b.Args, Shell: b.Shell})
+	if err != nil {
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid command",
+			Detail:   fmt.Sprintf("The command of source %q is invalid: %s.", b.Name, err),
+			Subject:  &rng,
+		})
+	}
+	if _, err := exec.LookPath(cmd.Executable()); err != nil {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Command not found",
+			Detail:   fmt.Sprintf("The executable %q of source %q could not be found: %s.", cmd.Executable(), b.Name, err),
+			Subject:  &rng,
+		})
+	}
+	if b.WorkingDir != "" {
+		if info, err := os.Stat(b.WorkingDir); err != nil || !info.IsDir() {
+			rng := b.attrRange("working_dir")
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Directory not found",
+				Detail:   fmt.Sprintf("The working_dir %q of source %q is not a directory.", b.WorkingDir, b.Name),
+				Subject:  &rng,
+			})
+		}
+	}
+	if b.StdinFile != "" && !fsutil.Exists(b.StdinFile) {
+		rng := b.attrRange("stdin_file")
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "File not found",
+			Detail:   fmt.Sprintf("The stdin_file %q of source %q does not exist.", b.StdinFile, b.Name),
+			Subject:  &rng,
+		})
+	}
+	if _, err := b.ToSupplier(nil); err != nil {
+		diags = append(diags, b.invalidSourceDiag(err, b.DeclRange))
+	}
+	return diags
+}
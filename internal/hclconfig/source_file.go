@@ -0,0 +1,70 @@
+package hclconfig
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/scnewma/sgen/internal/fsutil"
+	"github.com/scnewma/sgen/internal/sgen"
+	"github.com/scnewma/sgen/internal/sgen/supply"
+)
+
+var fileSourceType = newSourceType("file", "Reads records from a JSON, JSON lines, YAML, CSV or TSV file.", decodeFileSource)
+
+type FileSourceBlock struct {
+	SourceBlock
+	Path      string
+	Format    string
+	Delimiter string
+	HasHeader *bool
+	Columns   []string
+}
+
+type fileAttributes struct {
+	Path      string   `hcl:"path"`
+	Format    string   `hcl:"format,optional"`
+	Delimiter string   `hcl:"delimiter,optional"`
+	HasHeader *bool    `hcl:"has_header,optional"`
+	Columns   []string `hcl:"columns,optional"`
+}
+
+func decodeFileSource(sb SourceBlock, attrs *fileAttributes, _ *hcl.Block) (Source, hcl.Diagnostics) {
+	return &FileSourceBlock{
+		SourceBlock: sb,
+		Path:        attrs.Path,
+		Format:      attrs.Format,
+		Delimiter:   attrs.Delimiter,
+		HasHeader:   attrs.HasHeader,
+		Columns:     attrs.Columns,
+	}, nil
+}
+
+func (b *FileSourceBlock) ToSupplier(map[string]*sgen.Source) (sgen.Supplier, error) {
+	return supply.NewFileSupply(b.Path, supply.FileOptions{
+		Format:    b.Format,
+		Delimiter: b.Delimiter,
+		HasHeader: b.HasHeader,
+		Columns:   b.Columns,
+		Select:    b.Select,
+	})
+}
+
+// Validate checks that the file exists in addition to the common checks.
+func (b *FileSourceBlock) Validate() hcl.Diagnostics {
+	diags := b.SourceBlock.Validate()
+	if !fsutil.Exists(b.Path) {
+		rng := b.attrRange("path")
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "File not found",
+			Detail:   fmt.Sprintf("The path %q of source %q does not exist.", b.Path, b.Name),
+			Subject:  &rng,
+		})
+		return diags
+	}
+	if _, err := b.ToSupplier(nil); err != nil {
+		diags = append(diags, b.invalidSourceDiag(err, b.DeclRange))
+	}
+	return diags
+}
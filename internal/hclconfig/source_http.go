@@ -0,0 +1,93 @@
+package hclconfig
+
+import (
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/scnewma/sgen/internal/sgen"
+	"github.com/scnewma/sgen/internal/sgen/supply"
+)
+
+var httpSourceType = newSourceType("http", "Requests records from a JSON HTTP API, following paginated responses.", decodeHTTPSource)
+
+type HTTPSourceBlock struct {
+	SourceBlock
+	URL            string
+	Method         string
+	Headers        map[string]string
+	BearerTokenEnv string
+	Body           string
+	Items          string
+	Pagination     *HTTPPagination
+	Timeout        time.Duration
+}
+
+type HTTPPagination struct {
+	Type        string `hcl:"type"`
+	CursorPath  string `hcl:"cursor_path,optional"`
+	CursorParam string `hcl:"cursor_param,optional"`
+	PageParam   string `hcl:"page_param,optional"`
+	StartPage   int    `hcl:"start_page,optional"`
+	MaxPages    int    `hcl:"max_pages,optional"`
+}
+
+type httpAttributes struct {
+	URL            string            `hcl:"url"`
+	Method         string            `hcl:"method,optional"`
+	Headers        map[string]string `hcl:"headers,optional"`
+	BearerTokenEnv string            `hcl:"bearer_token_env,optional"`
+	Body           string            `hcl:"body,optional"`
+	Items          string            `hcl:"items,optional"`
+	Timeout        *string           `hcl:"timeout,optional"`
+	Pagination     *HTTPPagination   `hcl:"pagination,block"`
+}
+
+func decodeHTTPSource(sb SourceBlock, attrs *httpAttributes, block *hcl.Block) (Source, hcl.Diagnostics) {
+	timeout, diags := decodeTimeout(sb, attrs.Timeout, block)
+	return &HTTPSourceBlock{
+		SourceBlock:    sb,
+		URL:            attrs.URL,
+		Method:         attrs.Method,
+		Headers:        attrs.Headers,
+		BearerTokenEnv: attrs.BearerTokenEnv,
+		Body:           attrs.Body,
+		Items:          attrs.Items,
+		Pagination:     attrs.Pagination,
+		Timeout:        timeout,
+	}, diags
+}
+
+func (b *HTTPSourceBlock) ToSupplier(map[string]*sgen.Source) (sgen.Supplier, error) {
+	opts := supply.HTTPOptions{
+		URL:            b.URL,
+		Method:         b.Method,
+		Headers:        b.Headers,
+		BearerTokenEnv: b.BearerTokenEnv,
+		Body:           b.Body,
+		Items:          b.Items,
+		Select:         b.Select,
+		Timeout:        b.Timeout,
+	}
+	if p := b.Pagination; p != nil {
+		opts.Pagination = &supply.Pagination{
+			Type:        p.Type,
+			CursorPath:  p.CursorPath,
+			CursorParam: p.CursorParam,
+			PageParam:   p.PageParam,
+			StartPage:   p.StartPage,
+			MaxPages:    p.MaxPages,
+		}
+	}
+	return supply.NewHTTPSupply(opts)
+}
+
+// Validate checks that the request can be built in addition to the common
+// checks.
+func (b *HTTPSourceBlock) Validate() hcl.Diagnostics {
+	diags := b.SourceBlock.Validate()
+	if _, err := b.ToSupplier(nil); err != nil {
+		diags = append(diags, b.invalidSourceDiag(err, b.DeclRange))
+	}
+	return diags
+}
@@ -0,0 +1,83 @@
+package hclconfig
+
+import (
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/scnewma/sgen/internal/sgen"
+	"github.com/scnewma/sgen/internal/sgen/supply"
+)
+
+var joinSourceType = newSourceType("join", "Merges the records of two other sources by key.", decodeJoinSource)
+
+// JoinSourceBlock merges the records of two other sources by key.
+type JoinSourceBlock struct {
+	SourceBlock
+	Left     string
+	Right    string
+	LeftKey  string
+	RightKey string
+	JoinType string
+	// RightPrefix is prepended to the names of the right record's fields.
+	RightPrefix string
+}
+
+type joinAttributes struct {
+	Left        string `hcl:"left"`
+	Right       string `hcl:"right"`
+	Key         string `hcl:"key,optional"`
+	LeftKey     string `hcl:"left_key,optional"`
+	RightKey    string `hcl:"right_key,optional"`
+	JoinType    string `hcl:"join_type,optional"`
+	RightPrefix string `hcl:"right_prefix,optional"`
+}
+
+func decodeJoinSource(sb SourceBlock, attrs *joinAttributes, block *hcl.Block) (Source, hcl.Diagnostics) {
+	source := &JoinSourceBlock{
+		SourceBlock: sb,
+		Left:        attrs.Left,
+		Right:       attrs.Right,
+		JoinType:    attrs.JoinType,
+		RightPrefix: attrs.RightPrefix,
+	}
+
+	// key is short for the same left_key and right_key
+	var diags hcl.Diagnostics
+	source.LeftKey, source.RightKey = attrs.LeftKey, attrs.RightKey
+	if attrs.Key != "" {
+		if attrs.LeftKey != "" || attrs.RightKey != "" {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Conflicting join keys",
+				Detail:   "Set either key, when the field has the same name in both sources, or left_key and right_key.",
+				Subject:  block.DefRange.Ptr(),
+			})
+		}
+		source.LeftKey, source.RightKey = attrs.Key, attrs.Key
+	}
+	return source, diags
+}
+
+func (b *JoinSourceBlock) GetInputs() []string {
+	return []string{b.Left, b.Right}
+}
+
+func (b *JoinSourceBlock) ToSupplier(inputs map[string]*sgen.Source) (sgen.Supplier, error) {
+	return supply.NewJoinSupply(supply.JoinOptions{
+		Left:        inputs[b.Left],
+		Right:       inputs[b.Right],
+		LeftKey:     b.LeftKey,
+		RightKey:    b.RightKey,
+		Type:        b.JoinType,
+		RightPrefix: b.RightPrefix,
+	})
+}
+
+// Validate checks the join keys and type in addition to the common checks.
+// The sources being joined are checked when the config is parsed.
+func (b *JoinSourceBlock) Validate() hcl.Diagnostics {
+	diags := b.SourceBlock.Validate()
+	if _, err := b.ToSupplier(nil); err != nil {
+		diags = append(diags, b.invalidSourceDiag(err, b.DeclRange))
+	}
+	return diags
+}
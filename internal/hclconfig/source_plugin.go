@@ -0,0 +1,336 @@
+package hclconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/scnewma/sgen/internal/fsutil"
+	"github.com/scnewma/sgen/internal/sgen"
+	"github.com/scnewma/sgen/internal/sgen/supply"
+)
+
+// pluginSchemaTimeout is how long a plugin has to report its schema, since it
+// is read every time the configuration is parsed.
+const pluginSchemaTimeout = 10 * time.Second
+
+// PluginSourceBlock is a source whose type is supplied by a plugin, see
+// supply.PluginProtocol.
+type PluginSourceBlock struct {
+	SourceBlock
+	// Plugin is the path of the plugin executable.
+	Plugin string
+	// Attributes are the block's attributes declared by the plugin's schema,
+	// as JSON, by name.
+	Attributes map[string]json.RawMessage
+	Cache      bool
+}
+
+func (b *PluginSourceBlock) ToSupplier(map[string]*sgen.Source) (sgen.Supplier, error) {
+	return supply.NewPluginSupply(supply.PluginOptions{
+		Path:       b.Plugin,
+		Type:       b.Type,
+		Source:     b.Name,
+		Attributes: b.Attributes,
+		Cache:      b.Cache,
+		Select:     b.Select,
+	})
+}
+
+// Validate checks that the supplier can be built in addition to the common
+// checks. The attributes are checked against the plugin's schema when the
+// config is parsed.
+func (b *PluginSourceBlock) Validate() hcl.Diagnostics {
+	diags := b.SourceBlock.Validate()
+	if _, err := b.ToSupplier(nil); err != nil {
+		diags = append(diags, b.invalidSourceDiag(err, b.DeclRange))
+	}
+	return diags
+}
+
+// pluginTypes finds the source types supplied by plugins, either set with a
+// plugin block or named sgen-supplier-<type> on the PATH. Each plugin's
+// schema is only looked up once per parse, and is cached between parses, see
+// readPluginSchema.
+type pluginTypes struct {
+	// paths are the plugins set with plugin blocks by type, and ranges the
+	// blocks' headers
+	paths  map[string]string
+	ranges map[string]hcl.Range
+	found  map[string]pluginLookup
+	// cacheDir is where schemas are cached, the default cache directory when
+	// empty
+	cacheDir string
+}
+
+type pluginLookup struct {
+	st  SourceType
+	ok  bool
+	err error
+}
+
+func newPluginTypes() *pluginTypes {
+	return &pluginTypes{
+		paths:  make(map[string]string),
+		ranges: make(map[string]hcl.Range),
+		found:  make(map[string]pluginLookup),
+	}
+}
+
+// decodeBlock records the plugin set by a plugin block. The path is relative
+// to the directory of filename.
+func (p *pluginTypes) decodeBlock(context *hcl.EvalContext, filename string, override bool, block *hcl.Block) hcl.Diagnostics {
+	typ := block.Labels[0]
+	var b struct {
+		Path string `hcl:"path"`
+	}
+	diags := gohcl.DecodeBody(block.Body, context, &b)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	if _, builtin := LookupSourceType(typ); builtin {
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Built in source type",
+			Detail:   fmt.Sprintf("The source type %q is built in, so it can't be supplied by a plugin.", typ),
+			Subject:  block.LabelRanges[0].Ptr(),
+		})
+	}
+	if prev, found := p.ranges[typ]; found && !override {
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Duplicate plugin",
+			Detail:   fmt.Sprintf("A plugin for source type %q was already set at %s. To replace it, set it again in an override file.", typ, prev),
+			Subject:  block.DefRange.Ptr(),
+		})
+	}
+
+	path := b.Path
+	if rest, found := strings.CutPrefix(path, "~/"); found {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(filename), path)
+	}
+	p.paths[typ] = path
+	p.ranges[typ] = block.DefRange
+	return diags
+}
+
+// lookup returns the source type supplied by the plugin for typ, if there is
+// one.
+func (p *pluginTypes) lookup(typ string) (SourceType, bool, error) {
+	if l, found := p.found[typ]; found {
+		return l.st, l.ok, l.err
+	}
+
+	var l pluginLookup
+	path, configured := p.paths[typ]
+	if !configured {
+		var err error
+		if path, err = supply.FindPlugin(typ); err != nil {
+			p.found[typ] = l
+			return l.st, l.ok, l.err
+		}
+	}
+
+	schema, err := readPluginSchema(path, p.cacheDir)
+	if err == nil {
+		l.st, err = pluginSourceType(typ, path, schema)
+	}
+	l.ok, l.err = err == nil, err
+	p.found[typ] = l
+	return l.st, l.ok, l.err
+}
+
+// types returns the source types of the plugins set with plugin blocks and
+// of those on the PATH, sorted by name. Plugins that can't be used are
+// returned as errors.
+func (p *pluginTypes) types() ([]SourceType, error) {
+	typs := make(map[string]bool)
+	for typ := range p.paths {
+		typs[typ] = true
+	}
+	for typ := range supply.FindPlugins() {
+		if _, builtin := LookupSourceType(typ); !builtin {
+			typs[typ] = true
+		}
+	}
+
+	var sts []SourceType
+	var errs []error
+	for typ := range typs {
+		st, found, err := p.lookup(typ)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin for source type %q: %w", typ, err))
+		} else if found {
+			sts = append(sts, st)
+		}
+	}
+	sort.Slice(sts, func(i, j int) bool {
+		return sts[i].Name < sts[j].Name
+	})
+	return sts, errors.Join(errs...)
+}
+
+// cachedPluginSchema is a plugin's schema cached with the plugin's path and
+// modification time, so that it is read again when the plugin changes.
+type cachedPluginSchema struct {
+	Path    string               `json:"path"`
+	ModTime time.Time            `json:"mod_time"`
+	Size    int64                `json:"size"`
+	Schema  *supply.PluginSchema `json:"schema"`
+}
+
+// readPluginSchema returns the schema of the plugin at path, from the cache in
+// cacheDir, or the default cache directory when empty, unless the plugin has
+// changed since it was cached. The schema is otherwise read every time the
+// configuration is parsed. Failing to cache it only means it is read from the
+// plugin again next time.
+func readPluginSchema(path, cacheDir string) (*supply.PluginSchema, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if cacheDir == "" {
+		// without a cache directory the schema is read every time
+		cacheDir, _ = sgen.CacheDir()
+	}
+	var cachePath string
+	if cacheDir != "" {
+		sum := sha256.Sum256([]byte(abs))
+		cachePath = filepath.Join(cacheDir, "plugins", hex.EncodeToString(sum[:])+".json")
+	}
+
+	var cached cachedPluginSchema
+	if cachePath != "" && fsutil.ReadJSON(cachePath, &cached) == nil && cached.Schema != nil &&
+		cached.Path == abs && cached.ModTime.Equal(info.ModTime()) && cached.Size == info.Size() {
+		return cached.Schema, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginSchemaTimeout)
+	defer cancel()
+	schema, err := supply.ReadPluginSchema(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if cachePath != "" {
+		_ = fsutil.WriteJSON(cachePath, cachedPluginSchema{Path: abs, ModTime: info.ModTime(), Size: info.Size(), Schema: schema})
+	}
+	return schema, nil
+}
+
+// pluginSourceType declares a source type from a plugin's schema. The
+// attributes are decoded with the types the schema declares and sent to the
+// plugin as JSON.
+func pluginSourceType(typ, path string, schema *supply.PluginSchema) (SourceType, error) {
+	bodySchema := &hcl.BodySchema{}
+	types := make(map[string]cty.Type, len(schema.Attributes))
+	descriptions := make(map[string]string)
+	for _, attr := range schema.Attributes {
+		if attr.Description != "" {
+			descriptions[attr.Name] = attr.Description
+		}
+		ty, err := pluginAttributeType(attr.Type)
+		if err != nil {
+			return SourceType{}, fmt.Errorf("plugin %s: attribute %q: %w", path, attr.Name, err)
+		}
+		types[attr.Name] = ty
+		bodySchema.Attributes = append(bodySchema.Attributes, hcl.AttributeSchema{Name: attr.Name, Required: attr.Required})
+	}
+	cache := schema.Cache == nil || *schema.Cache
+
+	decode := func(sb SourceBlock, body hcl.Body, context *hcl.EvalContext, block *hcl.Block) (Source, hcl.Diagnostics) {
+		content, diags := body.Content(bodySchema)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		attrs := make(map[string]json.RawMessage)
+		for name, attr := range content.Attributes {
+			val, moreDiags := attr.Expr.Value(context)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() || val.IsNull() {
+				continue
+			}
+			raw, err := pluginAttributeJSON(val, types[name])
+			if err != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Incorrect attribute value type",
+					Detail:   fmt.Sprintf("Inappropriate value for attribute %q: %s.", name, err),
+					Subject:  attr.Expr.Range().Ptr(),
+				})
+				continue
+			}
+			attrs[name] = raw
+		}
+		return &PluginSourceBlock{
+			SourceBlock: sb,
+			Plugin:      path,
+			Attributes:  attrs,
+			Cache:       cache,
+		}, diags
+	}
+
+	return SourceType{
+		Name:         typ,
+		Summary:      schema.Summary,
+		Schema:       func() *hcl.BodySchema { return bodySchema },
+		Descriptions: descriptions,
+		Decode:       decode,
+	}, nil
+}
+
+// pluginAttributeType parses the type of a plugin's attribute, an HCL type
+// constraint such as string or list(string). Attributes without a type can
+// be any value.
+func pluginAttributeType(s string) (cty.Type, error) {
+	if s == "" {
+		return cty.DynamicPseudoType, nil
+	}
+	expr, diags := hclsyntax.ParseExpression([]byte(s), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilType, fmt.Errorf("invalid type %q", s)
+	}
+	ty, diags := typeexpr.TypeConstraint(expr)
+	if diags.HasErrors() {
+		return cty.NilType, fmt.Errorf("invalid type %q: %s", s, diags[0].Detail)
+	}
+	return ty, nil
+}
+
+// pluginAttributeJSON converts val to ty and encodes it as JSON.
+func pluginAttributeJSON(val cty.Value, ty cty.Type) (json.RawMessage, error) {
+	val, err := convert.Convert(val, ty)
+	if err != nil {
+		return nil, err
+	}
+	if !val.IsWhollyKnown() {
+		return nil, fmt.Errorf("the value isn't known")
+	}
+	return ctyjson.Marshal(val, val.Type())
+}
@@ -0,0 +1,55 @@
+package hclconfig
+
+import (
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/scnewma/sgen/internal/sgen"
+	"github.com/scnewma/sgen/internal/sgen/supply"
+)
+
+var unionSourceType = newSourceType("union", "Concatenates the records of several other sources.", decodeUnionSource)
+
+// UnionSourceBlock concatenates the records of several other sources.
+type UnionSourceBlock struct {
+	SourceBlock
+	Sources     []string
+	SourceField string
+}
+
+type unionAttributes struct {
+	Sources     []string `hcl:"sources"`
+	SourceField string   `hcl:"source_field,optional"`
+}
+
+func decodeUnionSource(sb SourceBlock, attrs *unionAttributes, _ *hcl.Block) (Source, hcl.Diagnostics) {
+	return &UnionSourceBlock{
+		SourceBlock: sb,
+		Sources:     attrs.Sources,
+		SourceField: attrs.SourceField,
+	}, nil
+}
+
+func (b *UnionSourceBlock) GetInputs() []string {
+	return b.Sources
+}
+
+func (b *UnionSourceBlock) ToSupplier(inputs map[string]*sgen.Source) (sgen.Supplier, error) {
+	var sources []supply.UnionInput
+	for _, name := range b.Sources {
+		sources = append(sources, supply.UnionInput{Name: name, Source: inputs[name]})
+	}
+	return supply.NewUnionSupply(supply.UnionOptions{
+		Sources: sources,
+		Field:   b.SourceField,
+	})
+}
+
+// Validate checks that there is at least one source to concatenate in
+// addition to the common checks.
+func (b *UnionSourceBlock) Validate() hcl.Diagnostics {
+	diags := b.SourceBlock.Validate()
+	if _, err := b.ToSupplier(nil); err != nil {
+		diags = append(diags, b.invalidSourceDiag(err, b.attrRange("sources")))
+	}
+	return diags
+}
@@ -1,5 +1,27 @@
 source "command" "gh" {
   command = "gh repo list --json nameWithOwner"
+  ttl = "6h"
+}
+
+source "command" "gh_enterprise" {
+  command = "gh repo list --json nameWithOwner"
+  env = {
+    GH_HOST = "github.example.com"
+  }
+  inherit_env = false
+  working_dir = "/tmp"
+  stdin_file = "/dev/null"
+  timeout = "30s"
+}
+
+source "command" "gh_names" {
+  args = ["gh", "api", "-q", ".[] | {name}", "/orgs/scnewma/repos"]
+}
+
+source "command" "gh_bash" {
+  command = "!gh repo list --json nameWithOwner | jq -c '.[]'"
+  shell = "bash"
+  format = "jsonl"
 }
 
 source "command" "gh_w_template" {
@@ -18,6 +40,7 @@ source "command" "gh_w_template" {
 
 source "file" "static" {
   path = "/data.json"
+  sort_by = ["name", "owner.login"]
 }
 
 source "file" "static_w_template" {
@@ -33,3 +56,43 @@ source "file" "static_w_template" {
     value = "{{.name}}"
   }
 }
+
+source "file" "inventory" {
+  path = "/inventory.txt"
+  format = "csv"
+  delimiter = ";"
+  has_header = false
+  columns = ["host", "env"]
+}
+
+source "http" "api" {
+  url = "https://api.github.com/orgs/scnewma/repos"
+  headers = {
+    Accept = "application/vnd.github+json"
+  }
+  bearer_token_env = "GITHUB_TOKEN"
+  timeout = "10s"
+
+  pagination {
+    type = "link"
+  }
+
+  template {
+    name = "default"
+    value = "{{.full_name}}"
+  }
+}
+
+source "http" "api_cursor" {
+  url = "https://example.com/api/items"
+  method = "POST"
+  body = "{}"
+  items = "data.items"
+
+  pagination {
+    type = "cursor"
+    cursor_path = "meta.next"
+    cursor_param = "after"
+    max_pages = 10
+  }
+}
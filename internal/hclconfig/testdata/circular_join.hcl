@@ -0,0 +1,15 @@
+source "file" "repos" {
+  path = "repos.json"
+}
+
+source "join" "a" {
+  left  = "b"
+  right = "repos"
+  key   = "id"
+}
+
+source "join" "b" {
+  left  = "a"
+  right = "repos"
+  key   = "id"
+}
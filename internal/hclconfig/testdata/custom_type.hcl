@@ -0,0 +1,7 @@
+source "static" "colors" {
+  values = ["red", "green"]
+}
+
+source "shell" "unknown" {
+  command = "echo"
+}
@@ -0,0 +1,3 @@
+source "command" "dup" {
+  command = "echo a"
+}
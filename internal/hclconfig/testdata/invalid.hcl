@@ -0,0 +1,24 @@
+source "command" "a_missing_command" {
+  command = "sgen-command-that-does-not-exist --json"
+}
+
+source "command" "b_bad_template" {
+  command = "!echo '[]'"
+
+  template {
+    name = "default"
+    value = "{{.name"
+  }
+}
+
+source "file" "c_missing_file" {
+  path = "/does/not/exist.json"
+}
+
+source "command" "d_bad_alfred_template" {
+  command = "!echo '[]'"
+
+  alfred {
+    subtitle = "{{.owner"
+  }
+}
@@ -0,0 +1,8 @@
+source "command" "both" {
+  command = "gh repo list --json nameWithOwner"
+  args = ["gh", "repo", "list", "--json", "nameWithOwner"]
+}
+
+source "command" "neither" {
+  format = "jsonl"
+}
@@ -0,0 +1,9 @@
+source "file" "repos" {
+  path = "repos.json"
+}
+
+source "join" "repo-owners" {
+  left  = "repos"
+  right = "users"
+  key   = "login"
+}
@@ -0,0 +1,6 @@
+variable "required" {}
+
+locals {
+  a = local.b
+  b = local.a
+}
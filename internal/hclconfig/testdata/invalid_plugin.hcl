@@ -0,0 +1,21 @@
+plugin "jira" {
+  path = "plugins/sgen-supplier-tickets"
+}
+
+source "jira" "missing_project" {
+  limit = 10
+}
+
+source "jira" "bad_limit" {
+  project = "SGEN"
+  limit   = "lots"
+}
+
+source "jira" "unknown_attribute" {
+  project = "SGEN"
+  board   = 1
+}
+
+plugin "file" {
+  path = "plugins/sgen-supplier-tickets"
+}
@@ -0,0 +1,4 @@
+source "command" "pods" {
+  command = "kubectl get pods -o json"
+  select = ".items[ | {name: .metadata.name}"
+}
@@ -0,0 +1,9 @@
+source "file" "names" {
+  path = "names.json"
+
+  template {
+    name  = "table"
+    mode  = "table"
+    value = "{{range .Items}}{{.name}}{{end}}"
+  }
+}
@@ -0,0 +1,4 @@
+source "command" "gh" {
+  command = "gh repo list --json nameWithOwner"
+  timeout = "-1s"
+}
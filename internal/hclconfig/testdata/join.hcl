@@ -0,0 +1,16 @@
+source "file" "repos" {
+  path = "repos.json"
+}
+
+source "file" "users" {
+  path = "users.json"
+}
+
+source "join" "repo-owners" {
+  left      = "repos"
+  right     = "users"
+  left_key  = "owner.login"
+  right_key = "login"
+  join_type = "left"
+  right_prefix = "owner_"
+}
@@ -0,0 +1,5 @@
+include "sources.d" {}
+
+source "file" "a" {
+  path = "${sgen.directory}/a.json"
+}
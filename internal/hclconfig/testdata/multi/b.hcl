@@ -0,0 +1,6 @@
+# including a file that's already loaded is a no-op
+include "a.hcl" {}
+
+source "command" "b" {
+  command = "echo b"
+}
@@ -0,0 +1,3 @@
+source "command" "d" {
+  command = "echo local d"
+}
@@ -0,0 +1,7 @@
+source "command" "c" {
+  command = "echo c"
+}
+
+source "command" "d" {
+  command = "echo d"
+}
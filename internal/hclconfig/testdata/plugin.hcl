@@ -0,0 +1,13 @@
+plugin "jira" {
+  path = "plugins/sgen-supplier-tickets"
+}
+
+source "jira" "sgen" {
+  project = "SGEN"
+  labels  = ["bug", "docs"]
+  limit   = "50"
+}
+
+source "tickets" "all" {
+  project = "ALL"
+}
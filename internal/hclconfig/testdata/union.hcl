@@ -0,0 +1,12 @@
+source "file" "work" {
+  path = "work.json"
+}
+
+source "file" "personal" {
+  path = "personal.json"
+}
+
+source "union" "all" {
+  sources      = ["work", "personal"]
+  source_field = "origin"
+}
@@ -0,0 +1,23 @@
+variable "org" {}
+
+variable "limit" {
+  default = 10
+}
+
+locals {
+  # locals may reference locals defined after them
+  repos = format("gh repo list %s --limit %d", local.org, var.limit)
+  org   = lower(var.org)
+}
+
+source "command" "repos" {
+  command = local.repos
+}
+
+source "file" "home" {
+  path = join("/", [homedir(), "data.json"])
+}
+
+source "command" "env" {
+  command = "echo ${env("SGEN_TEST_UNSET", "fallback")}"
+}
@@ -0,0 +1,85 @@
+package hclconfig
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/scnewma/sgen/internal/sgen"
+)
+
+// Validate checks every source in the config, see Source.Validate.
+func Validate(config *Config) hcl.Diagnostics {
+	var names []string
+	for name := range config.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diags hcl.Diagnostics
+	for _, name := range names {
+		diags = append(diags, config.Sources[name].Validate()...)
+	}
+	return diags
+}
+
+// Validate compiles each of the source's templates.
+func (b *SourceBlock) Validate() hcl.Diagnostics {
+	var names []string
+	for name := range b.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diags hcl.Diagnostics
+	for _, name := range names {
+		if _, err := sgen.NewGoTemplateRenderer(b.Templates[name]); err != nil {
+			rng := b.templateRange(name)
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid template",
+				Detail:   fmt.Sprintf("Template %q of source %q does not compile: %s.", name, b.Name, err),
+				Subject:  &rng,
+			})
+		}
+	}
+	if b.Alfred != nil {
+		if _, err := sgen.NewItemRenderer(b.Alfred.ItemTemplates()); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid template",
+				Detail:   fmt.Sprintf("The alfred block of source %q has a template that does not compile: %s.", b.Name, err),
+				Subject:  b.AlfredRange.Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// attrRange returns the range of the named attribute's expression, falling
+// back to the block header if the attribute's range isn't known.
+func (b *SourceBlock) attrRange(name string) hcl.Range {
+	if rng, found := b.AttrRanges[name]; found {
+		return rng
+	}
+	return b.DeclRange
+}
+
+// invalidSourceDiag reports that the source's supplier can't be built,
+// pointing at rng.
+func (b *SourceBlock) invalidSourceDiag(err error, rng hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("Invalid %s source", b.Type),
+		Detail:   fmt.Sprintf("Source %q is invalid: %s.", b.Name, err),
+		Subject:  &rng,
+	}
+}
+
+func (b *SourceBlock) templateRange(name string) hcl.Range {
+	if rng, found := b.TemplateRanges[name]; found {
+		return rng
+	}
+	return b.DeclRange
+}
@@ -0,0 +1,70 @@
+package records
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Field describes a field found in a set of records.
+type Field struct {
+	Path string `json:"path"`
+	// Types are the JSON types the field was found with, i.e. a field that is
+	// sometimes null has the types ["null", "string"].
+	Types []string `json:"types"`
+}
+
+// Fields returns every field found in data, including the fields of nested
+// objects, sorted by path.
+func Fields(data []map[string]any) []Field {
+	types := map[string]map[string]bool{}
+	for _, record := range data {
+		collectFields(types, "", record)
+	}
+
+	fields := make([]Field, 0, len(types))
+	for path, set := range types {
+		f := Field{Path: path}
+		for typ := range set {
+			f.Types = append(f.Types, typ)
+		}
+		sort.Strings(f.Types)
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Path < fields[j].Path
+	})
+	return fields
+}
+
+func collectFields(types map[string]map[string]bool, prefix string, obj map[string]any) {
+	for k, v := range obj {
+		path := prefix + k
+		if types[path] == nil {
+			types[path] = map[string]bool{}
+		}
+		types[path][TypeName(v)] = true
+
+		if nested, ok := v.(map[string]any); ok {
+			collectFields(types, path+".", nested)
+		}
+	}
+}
+
+// TypeName returns the JSON type name of v.
+func TypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
@@ -0,0 +1,28 @@
+package records
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFields(t *testing.T) {
+	data := []map[string]any{
+		{"name": "sgen", "owner": map[string]any{"login": "scnewma"}, "license": nil},
+		{"name": "old", "stars": float64(1), "license": map[string]any{"key": "mit"}, "topics": []any{"go"}},
+	}
+
+	expect := []Field{
+		{Path: "license", Types: []string{"null", "object"}},
+		{Path: "license.key", Types: []string{"string"}},
+		{Path: "name", Types: []string{"string"}},
+		{Path: "owner", Types: []string{"object"}},
+		{Path: "owner.login", Types: []string{"string"}},
+		{Path: "stars", Types: []string{"number"}},
+		{Path: "topics", Types: []string{"array"}},
+	}
+
+	if diff := cmp.Diff(expect, Fields(data)); diff != "" {
+		t.Errorf("Fields() mismatch (-want +got):\n%s", diff)
+	}
+}
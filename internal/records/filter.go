@@ -0,0 +1,470 @@
+package records
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/scnewma/sgen/internal/fieldpath"
+)
+
+// Filter is a compiled --where expression, i.e.
+//
+//	visibility == "private" && name matches "^api-"
+//
+// Fields are referenced by their dotted path (i.e. owner.login) and can be
+// compared against string, number, boolean and null literals with ==, !=, <,
+// <=, >, >=, matches (regular expression) and contains (substring or array
+// element). Comparisons can be combined with &&, || and ! (or and, or and
+// not) and grouped with parentheses. A field on its own is true when it is
+// set to anything other than false, 0, "", null or an empty array or object.
+type Filter struct {
+	expr string
+	root node
+}
+
+// ParseFilter compiles expr into a Filter.
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expr, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokEOF {
+		err = fmt.Errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expr, err)
+	}
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// Match reports whether the record satisfies the filter.
+func (f *Filter) Match(record map[string]any) bool {
+	return truthy(f.root.eval(record))
+}
+
+// String returns the expression the filter was compiled from.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Apply returns the records that match the filter.
+func (f *Filter) Apply(data []map[string]any) []map[string]any {
+	var matched []map[string]any
+	for _, record := range data {
+		if f.Match(record) {
+			matched = append(matched, record)
+		}
+	}
+	return matched
+}
+
+type node interface {
+	eval(record map[string]any) any
+}
+
+type literalNode struct{ value any }
+
+func (n literalNode) eval(map[string]any) any { return n.value }
+
+type fieldNode struct{ path string }
+
+func (n fieldNode) eval(record map[string]any) any {
+	v, _ := fieldpath.Lookup(record, n.path)
+	return v
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(record map[string]any) any { return !truthy(n.operand.eval(record)) }
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n logicalNode) eval(record map[string]any) any {
+	left := truthy(n.left.eval(record))
+	if n.op == "&&" {
+		return left && truthy(n.right.eval(record))
+	}
+	return left || truthy(n.right.eval(record))
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(record map[string]any) any {
+	left, right := n.left.eval(record), n.right.eval(record)
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	case "contains":
+		return contains(left, right)
+	}
+
+	c, ok := Compare(left, right)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+type matchNode struct {
+	operand node
+	re      *regexp.Regexp
+}
+
+func (n matchNode) eval(record map[string]any) any {
+	s, ok := n.operand.eval(record).(string)
+	return ok && n.re.MatchString(s)
+}
+
+// Compare orders a and b if they are both numbers, strings or booleans. ok is
+// false if the values cannot be ordered against each other.
+func Compare(a, b any) (c int, ok bool) {
+	if c, ok := compareNumbers(a, b); ok {
+		return c, true
+	}
+	switch a := a.(type) {
+	case string:
+		if b, isStr := b.(string); isStr {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, isBool := b.(bool); isBool {
+			switch {
+			case a == b:
+				return 0, true
+			case !a:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+func equal(a, b any) bool {
+	if c, ok := compareNumbers(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func contains(haystack, needle any) bool {
+	switch h := haystack.(type) {
+	case string:
+		n, ok := needle.(string)
+		return ok && strings.Contains(h, n)
+	case []any:
+		for _, v := range h {
+			if equal(v, needle) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case json.Number, float64:
+		c, ok := compareNumbers(v, 0.0)
+		return !ok || c != 0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	}
+	return true
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokField
+	tokString
+	tokNumber
+	tokKeyword // true, false, null
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q at position %d", t.text, t.pos+1)
+}
+
+var wordOps = map[string]string{
+	"and":      "&&",
+	"or":       "||",
+	"not":      "!",
+	"matches":  "matches",
+	"contains": "contains",
+}
+
+// lex splits s into tokens. Positions are byte offsets, but s is read a rune
+// at a time so that non-ASCII fields and strings aren't split part way through
+// a character.
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case c == utf8.RuneError && size == 1:
+			return nil, fmt.Errorf("invalid UTF-8 at position %d", i+1)
+		case unicode.IsSpace(c):
+			i += size
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(s) && s[end] != byte(c) {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			str := s[i+1 : end]
+			if c == '"' {
+				var err error
+				str, err = strconv.Unquote(s[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid string at position %d: %w", i+1, err)
+				}
+			}
+			tokens = append(tokens, token{tokString, str, i})
+			i = end + 1
+		case strings.ContainsRune("=!<>&|", c):
+			op := string(c)
+			if i+1 < len(s) {
+				if two := s[i : i+2]; two == "==" || two == "!=" || two == "<=" || two == ">=" || two == "&&" || two == "||" {
+					op = two
+				}
+			}
+			if op == "=" || op == "&" || op == "|" {
+				return nil, fmt.Errorf("unexpected %q at position %d", op, i+1)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		case c == '-' || unicode.IsDigit(c):
+			end := i + size
+			for end < len(s) {
+				r, n := utf8.DecodeRuneInString(s[end:])
+				if !unicode.IsDigit(r) && r != '.' && r != 'e' && r != 'E' {
+					break
+				}
+				end += n
+			}
+			tokens = append(tokens, token{tokNumber, s[i:end], i})
+			i = end
+		case c == '.' || c == '_' || unicode.IsLetter(c):
+			end := i + size
+			for end < len(s) {
+				r, n := utf8.DecodeRuneInString(s[end:])
+				if !isFieldChar(r) {
+					break
+				}
+				end += n
+			}
+			word := s[i:end]
+			switch {
+			case word == "true" || word == "false" || word == "null":
+				tokens = append(tokens, token{tokKeyword, word, i})
+			case wordOps[word] != "":
+				tokens = append(tokens, token{tokOp, wordOps[word], i})
+			default:
+				tokens = append(tokens, token{tokField, word, i})
+			}
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", c, i+1)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+func isFieldChar(c rune) bool {
+	return c == '.' || c == '_' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=", "matches", "contains") {
+		return left, nil
+	}
+
+	op := p.next()
+	if op.text == "matches" {
+		pattern := p.next()
+		if pattern.kind != tokString {
+			return nil, fmt.Errorf("matches requires a string pattern, got %s", pattern)
+		}
+		re, err := regexp.Compile(pattern.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		return matchNode{operand: left, re: re}, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: op.text, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("expected \")\", got %s", closing)
+		}
+		return n, nil
+	case tokField:
+		return fieldNode{path: t.text}, nil
+	case tokString:
+		return literalNode{value: t.text}, nil
+	case tokNumber:
+		if _, err := strconv.ParseFloat(t.text, 64); err != nil {
+			return nil, fmt.Errorf("invalid number %s", t)
+		}
+		return literalNode{value: json.Number(t.text)}, nil
+	case tokKeyword:
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		}
+		return literalNode{value: nil}, nil
+	}
+	return nil, fmt.Errorf("unexpected %s", t)
+}
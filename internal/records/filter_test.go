@@ -0,0 +1,88 @@
+package records
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	record := map[string]any{
+		"name":       "api-gateway",
+		"visibility": "private",
+		"stars":      json.Number("12"),
+		"id":         json.Number("9007199254740993"),
+		"score":      float64(0.5),
+		"isArchived": false,
+		"owner":      map[string]any{"login": "scnewma"},
+		"topics":     []any{"cli", "go"},
+		"license":    nil,
+		"city":       "Zürich",
+		"größe":      json.Number("3"),
+	}
+
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{expr: `visibility == "private"`, match: true},
+		{expr: `visibility != "private"`, match: false},
+		{expr: `name matches "^api-"`, match: true},
+		{expr: `name matches '^web-'`, match: false},
+		{expr: `owner.login == "scnewma"`, match: true},
+		{expr: `stars > 10 && stars <= 12`, match: true},
+		{expr: `stars < 10 || isArchived`, match: false},
+		{expr: `!isArchived`, match: true},
+		{expr: `not isArchived and topics contains "go"`, match: true},
+		{expr: `name contains "gate"`, match: true},
+		{expr: `license == null`, match: true},
+		{expr: `missing == null`, match: true},
+		{expr: `missing`, match: false},
+		{expr: `(stars > 100 || visibility == "private") && !(name == "web")`, match: true},
+		{expr: `stars > "10"`, match: false},
+		{expr: `stars == 12.0`, match: true},
+		{expr: `stars && score`, match: true},
+		{expr: `score < stars`, match: true},
+		{expr: `id == 9007199254740993`, match: true},
+		{expr: `id > 9007199254740992`, match: true},
+		{expr: `city == "Zürich"`, match: true},
+		{expr: `city == 'Zürich'`, match: true},
+		{expr: `city contains "ü"`, match: true},
+		{expr: `city == "Ångström"`, match: false},
+		{expr: `größe >= 3`, match: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := f.Match(record); got != tt.match {
+				t.Errorf("Match() = %v, want %v", got, tt.match)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	exprs := []string{
+		``,
+		`name ==`,
+		`name = "x"`,
+		`name == "unterminated`,
+		`(name == "x"`,
+		`name matches 42`,
+		`name matches "("`,
+		`name == "x" extra`,
+		"name == \xff",
+		`name == €`,
+	}
+
+	for _, expr := range exprs {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseFilter(expr); err == nil {
+				t.Errorf("expected error parsing %q", expr)
+			}
+		})
+	}
+}
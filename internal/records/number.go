@@ -0,0 +1,106 @@
+package records
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+)
+
+// isNumber reports whether v is a number, either a json.Number as decoded by
+// the suppliers or a float64.
+func isNumber(v any) bool {
+	switch v.(type) {
+	case json.Number, float64:
+		return true
+	}
+	return false
+}
+
+// compareNumbers orders a and b if they are both numbers. Integers are
+// compared exactly, since IDs are often too large for a float64.
+func compareNumbers(a, b any) (int, bool) {
+	if !isNumber(a) || !isNumber(b) {
+		return 0, false
+	}
+	if ai, ok := toInt(a); ok {
+		if bi, ok := toInt(b); ok {
+			switch {
+			case ai < bi:
+				return -1, true
+			case ai > bi:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	ar, aok := toRat(a)
+	br, bok := toRat(b)
+	if !aok || !bok {
+		return 0, false
+	}
+	return ar.Cmp(br), true
+}
+
+func toInt(v any) (int64, bool) {
+	if n, ok := v.(json.Number); ok {
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toRat(v any) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(string(v))
+	case float64:
+		r := new(big.Rat).SetFloat64(v)
+		return r, r != nil
+	}
+	return nil, false
+}
+
+// NativeNumbers returns v with every json.Number converted to an int64, or a
+// float64 if it isn't an integer that fits, for go templates. A json.Number is
+// a string to them, so it would be truthy when zero and couldn't be compared
+// with number literals. Maps and slices holding numbers are copied rather than
+// modified.
+func NativeNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, ok := toInt(v); ok {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v
+	case map[string]any:
+		return NativeRecord(v)
+	case []any:
+		converted := make([]any, len(v))
+		for i, elem := range v {
+			converted[i] = NativeNumbers(elem)
+		}
+		return converted
+	case []map[string]any:
+		converted := make([]map[string]any, len(v))
+		for i, elem := range v {
+			converted[i] = NativeRecord(elem)
+		}
+		return converted
+	}
+	return v
+}
+
+// NativeRecord is NativeNumbers for a record.
+func NativeRecord(record map[string]any) map[string]any {
+	if record == nil {
+		return nil
+	}
+	converted := make(map[string]any, len(record))
+	for k, v := range record {
+		converted[k] = NativeNumbers(v)
+	}
+	return converted
+}
@@ -0,0 +1,103 @@
+package records
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/scnewma/sgen/internal/encoding"
+	"github.com/scnewma/sgen/internal/fieldpath"
+)
+
+// CompareBy orders records a and b by the values of fields, in order, falling
+// back to later fields when earlier ones are equal. Values of different types
+// are ordered null, booleans, numbers, strings and then everything else, so
+// the order is always the same no matter what the records contain.
+func CompareBy(a, b map[string]any, fields []string) int {
+	for _, field := range fields {
+		av, _ := fieldpath.Lookup(a, field)
+		bv, _ := fieldpath.Lookup(b, field)
+		if c := compareValues(av, bv); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Sort stably sorts data by fields, see CompareBy.
+func Sort(data []map[string]any, fields []string, reverse bool) {
+	if len(fields) == 0 {
+		return
+	}
+	sort.SliceStable(data, func(i, j int) bool {
+		if reverse {
+			return CompareBy(data[j], data[i], fields) < 0
+		}
+		return CompareBy(data[i], data[j], fields) < 0
+	})
+}
+
+// UniqueKey identifies the value of field in record for de-duplication. ok is
+// false if record does not have field, in which case it should never be
+// considered a duplicate.
+func UniqueKey(record map[string]any, field string) (key string, ok bool) {
+	v, found := fieldpath.Lookup(record, field)
+	if !found {
+		return "", false
+	}
+	key, err := encoding.EncodeJSONString(v)
+	return key, err == nil
+}
+
+// Unique removes records whose value of field has already been seen, keeping
+// the first.
+func Unique(data []map[string]any, field string) []map[string]any {
+	seen := map[string]bool{}
+	var unique []map[string]any
+	for _, record := range data {
+		if key, ok := UniqueKey(record, field); ok {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		unique = append(unique, record)
+	}
+	return unique
+}
+
+// Limit returns at most n records. n <= 0 means no limit.
+func Limit(data []map[string]any, n int) []map[string]any {
+	if n > 0 && len(data) > n {
+		return data[:n]
+	}
+	return data
+}
+
+func compareValues(a, b any) int {
+	if c, ok := Compare(a, b); ok {
+		return c
+	}
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		return ra - rb
+	}
+	// neither value is orderable (i.e. objects or arrays), compare their
+	// encodings so that the order is at least deterministic
+	as, _ := encoding.EncodeJSONString(a)
+	bs, _ := encoding.EncodeJSONString(b)
+	return strings.Compare(as, bs)
+}
+
+func typeRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case json.Number, float64:
+		return 2
+	case string:
+		return 3
+	}
+	return 4
+}
@@ -0,0 +1,81 @@
+package records
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func names(data []map[string]any) []any {
+	var out []any
+	for _, record := range data {
+		out = append(out, record["name"])
+	}
+	return out
+}
+
+func TestSort(t *testing.T) {
+	data := []map[string]any{
+		{"name": "c", "stars": float64(1), "owner": map[string]any{"login": "b"}},
+		{"name": "a", "stars": float64(10), "owner": map[string]any{"login": "b"}},
+		{"name": "d"},
+		{"name": "b", "stars": float64(1), "owner": map[string]any{"login": "a"}},
+	}
+
+	tests := []struct {
+		fields  []string
+		reverse bool
+		expect  []any
+	}{
+		{fields: []string{"name"}, expect: []any{"a", "b", "c", "d"}},
+		{fields: []string{"name"}, reverse: true, expect: []any{"d", "c", "b", "a"}},
+		// missing values sort first, ties keep their original order
+		{fields: []string{"stars"}, expect: []any{"d", "c", "b", "a"}},
+		{fields: []string{"stars", "name"}, expect: []any{"d", "b", "c", "a"}},
+		{fields: []string{"owner.login"}, expect: []any{"d", "b", "c", "a"}},
+	}
+
+	for _, tt := range tests {
+		sorted := append([]map[string]any(nil), data...)
+		Sort(sorted, tt.fields, tt.reverse)
+		if diff := cmp.Diff(tt.expect, names(sorted)); diff != "" {
+			t.Errorf("Sort(%v, reverse=%v) mismatch (-want +got):\n%s", tt.fields, tt.reverse, diff)
+		}
+	}
+}
+
+func TestSortNumbers(t *testing.T) {
+	data := []map[string]any{
+		{"name": "c", "id": json.Number("9007199254740993")},
+		{"name": "b", "id": json.Number("9007199254740992")},
+		{"name": "a", "id": float64(1.5)},
+		{"name": "d", "id": json.Number("1e20")},
+	}
+	Sort(data, []string{"id"}, false)
+	if diff := cmp.Diff([]any{"a", "b", "c", "d"}, names(data)); diff != "" {
+		t.Errorf("Sort() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUniqueAndLimit(t *testing.T) {
+	data := []map[string]any{
+		{"name": "a", "owner": "x"},
+		{"name": "b", "owner": "y"},
+		{"name": "c", "owner": "x"},
+		{"name": "d"},
+		{"name": "e"},
+	}
+
+	unique := Unique(data, "owner")
+	if diff := cmp.Diff([]any{"a", "b", "d", "e"}, names(unique)); diff != "" {
+		t.Errorf("Unique() mismatch (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]any{"a", "b"}, names(Limit(unique, 2))); diff != "" {
+		t.Errorf("Limit() mismatch (-want +got):\n%s", diff)
+	}
+	if got := len(Limit(unique, 0)); got != len(unique) {
+		t.Errorf("Limit(0) returned %d records, want %d", got, len(unique))
+	}
+}
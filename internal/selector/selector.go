@@ -0,0 +1,444 @@
+// Package selector implements a small subset of jq for picking the records
+// out of a source's output, i.e.
+//
+//	.items[] | {name: .metadata.name, ns: .metadata.namespace}
+package selector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/scnewma/sgen/internal/records"
+)
+
+// Selector is a compiled select expression. Expressions are made up of:
+//
+//   - paths starting at the input, ".", with object keys (.owner.login or
+//     .["full name"]), array indices (.topics[0], negative indices count from
+//     the end) and iteration over every element of an array or value of an
+//     object (.items[])
+//   - object construction, {name: .metadata.name, ns: .metadata.namespace},
+//     where {name} is short for {name: .name}
+//   - pipes, a | b, which run b on every output of a
+//
+// JSONPath style expressions are also accepted for simple paths: $ is the
+// same as . and [*] the same as [].
+type Selector struct {
+	expr string
+	root node
+}
+
+// Parse compiles expr into a Selector.
+func Parse(expr string) (*Selector, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid select %q: %w", expr, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parsePipe()
+	if err == nil && p.peek().kind != tokEOF {
+		err = fmt.Errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid select %q: %w", expr, err)
+	}
+	return &Selector{expr: expr, root: root}, nil
+}
+
+// String returns the expression the selector was compiled from.
+func (s *Selector) String() string {
+	return s.expr
+}
+
+// Records runs the selector on v and returns its outputs as records. Outputs
+// that are arrays are expanded, so .items and .items[] select the same
+// records. null outputs are an error rather than no records, since they
+// usually mean a key in the expression is misspelled.
+func (s *Selector) Records(v any) ([]map[string]any, error) {
+	outputs, err := s.root.eval(v)
+	if err != nil {
+		return nil, fmt.Errorf("select %q: %w", s.expr, err)
+	}
+
+	data := []map[string]any{}
+	for _, out := range outputs {
+		if out == nil {
+			return nil, fmt.Errorf("select %q: selected null, check that the path exists in the data", s.expr)
+		}
+		items, isArr := out.([]any)
+		if !isArr {
+			items = []any{out}
+		}
+		for _, item := range items {
+			record, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("select %q: expected objects, got %s", s.expr, records.TypeName(item))
+			}
+			data = append(data, record)
+		}
+	}
+	return data, nil
+}
+
+type node interface {
+	// eval returns every output of the node for the input v.
+	eval(v any) ([]any, error)
+}
+
+type identityNode struct{}
+
+func (identityNode) eval(v any) ([]any, error) { return []any{v}, nil }
+
+type keyNode struct {
+	from node
+	key  string
+}
+
+func (n keyNode) eval(v any) ([]any, error) {
+	return each(n.from, v, func(in any) ([]any, error) {
+		switch in := in.(type) {
+		case nil:
+			return []any{nil}, nil
+		case map[string]any:
+			return []any{in[n.key]}, nil
+		}
+		return nil, fmt.Errorf("cannot index %s with %q", records.TypeName(in), n.key)
+	})
+}
+
+type indexNode struct {
+	from  node
+	index int
+}
+
+func (n indexNode) eval(v any) ([]any, error) {
+	return each(n.from, v, func(in any) ([]any, error) {
+		switch in := in.(type) {
+		case nil:
+			return []any{nil}, nil
+		case []any:
+			i := n.index
+			if i < 0 {
+				i += len(in)
+			}
+			if i < 0 || i >= len(in) {
+				return []any{nil}, nil
+			}
+			return []any{in[i]}, nil
+		}
+		return nil, fmt.Errorf("cannot index %s with %d", records.TypeName(in), n.index)
+	})
+}
+
+type iterateNode struct{ from node }
+
+func (n iterateNode) eval(v any) ([]any, error) {
+	return each(n.from, v, func(in any) ([]any, error) {
+		switch in := in.(type) {
+		case []any:
+			return in, nil
+		case map[string]any:
+			// object values are iterated in key order so the output is stable
+			keys := make([]string, 0, len(in))
+			for k := range in {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			values := make([]any, 0, len(in))
+			for _, k := range keys {
+				values = append(values, in[k])
+			}
+			return values, nil
+		}
+		return nil, fmt.Errorf("cannot iterate over %s", records.TypeName(in))
+	})
+}
+
+type pipeNode struct{ left, right node }
+
+func (n pipeNode) eval(v any) ([]any, error) {
+	return each(n.left, v, n.right.eval)
+}
+
+type objectEntry struct {
+	key   string
+	value node
+}
+
+type objectNode struct{ entries []objectEntry }
+
+// eval builds an object for every combination of the entries' outputs, like
+// jq, so {name: .name, tag: .tags[]} is an object per tag.
+func (n objectNode) eval(v any) ([]any, error) {
+	objects := []map[string]any{{}}
+	for _, e := range n.entries {
+		values, err := e.value.eval(v)
+		if err != nil {
+			return nil, err
+		}
+		next := make([]map[string]any, 0, len(objects)*len(values))
+		for _, obj := range objects {
+			for _, value := range values {
+				o := make(map[string]any, len(obj)+1)
+				for k, v := range obj {
+					o[k] = v
+				}
+				o[e.key] = value
+				next = append(next, o)
+			}
+		}
+		objects = next
+	}
+
+	out := make([]any, 0, len(objects))
+	for _, obj := range objects {
+		out = append(out, obj)
+	}
+	return out, nil
+}
+
+// each runs fn on every output of from.
+func each(from node, v any, fn func(any) ([]any, error)) ([]any, error) {
+	inputs, err := from.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, in := range inputs {
+		results, err := fn(in)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, results...)
+	}
+	return out, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokDot
+	tokIdent
+	tokString
+	tokNumber
+	tokPunct // [ ] { } ( ) : , | *
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q at position %d", t.text, t.pos+1)
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '.' || c == '$':
+			// $ is the JSONPath root, which is the same as jq's .
+			tokens = append(tokens, token{tokDot, string(c), i})
+			i++
+		case strings.ContainsRune("[]{}():,|*", c):
+			tokens = append(tokens, token{tokPunct, string(c), i})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			str, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %w", i+1, err)
+			}
+			tokens = append(tokens, token{tokString, str, i})
+			i = end + 1
+		case c == '-' || unicode.IsDigit(c):
+			end := i + 1
+			for end < len(s) && unicode.IsDigit(rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{tokNumber, s[i:end], i})
+			i = end
+		case c == '_' || unicode.IsLetter(c):
+			end := i + 1
+			for end < len(s) && (s[end] == '_' || unicode.IsLetter(rune(s[end])) || unicode.IsDigit(rune(s[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{tokIdent, s[i:end], i})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", c, i+1)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == text
+}
+
+func (p *parser) expectPunct(text string) error {
+	if t := p.next(); t.kind != tokPunct || t.text != text {
+		return fmt.Errorf("expected %q, got %s", text, t)
+	}
+	return nil
+}
+
+func (p *parser) parsePipe() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("|") {
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = pipeNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	switch {
+	case p.peek().kind == tokDot:
+		return p.parsePath()
+	case p.isPunct("{"):
+		return p.parseObject()
+	case p.isPunct("("):
+		p.next()
+		n, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("unexpected %s", p.peek())
+}
+
+// parsePath parses a path starting at the leading ".", i.e. .items[0].name.
+func (p *parser) parsePath() (node, error) {
+	p.next()
+	var n node = identityNode{}
+
+	// the first key follows the leading dot directly, .items rather than ..items
+	if t := p.peek(); t.kind == tokIdent || t.kind == tokString {
+		p.next()
+		n = keyNode{from: n, key: t.text}
+	}
+
+	for {
+		switch {
+		case p.peek().kind == tokDot:
+			p.next()
+			t := p.peek()
+			if t.kind == tokIdent || t.kind == tokString {
+				p.next()
+				n = keyNode{from: n, key: t.text}
+			} else if !p.isPunct("[") {
+				return nil, fmt.Errorf("expected a key after \".\", got %s", t)
+			}
+		case p.isPunct("["):
+			p.next()
+			t := p.next()
+			switch {
+			case t.kind == tokPunct && t.text == "]":
+				n = iterateNode{from: n}
+				continue
+			case t.kind == tokPunct && t.text == "*":
+				n = iterateNode{from: n}
+			case t.kind == tokNumber:
+				i, err := strconv.Atoi(t.text)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %s", t)
+				}
+				n = indexNode{from: n, index: i}
+			case t.kind == tokString:
+				n = keyNode{from: n, key: t.text}
+			default:
+				return nil, fmt.Errorf("expected an index, key or \"]\", got %s", t)
+			}
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parseObject() (node, error) {
+	p.next()
+	var obj objectNode
+	for !p.isPunct("}") {
+		t := p.next()
+		if t.kind != tokIdent && t.kind != tokString {
+			return nil, fmt.Errorf("expected a key, got %s", t)
+		}
+		entry := objectEntry{key: t.text}
+
+		if p.isPunct(":") {
+			p.next()
+			value, err := p.parseTerm()
+			if err != nil {
+				return nil, err
+			}
+			entry.value = value
+		} else {
+			// {name} is short for {name: .name}
+			entry.value = keyNode{from: identityNode{}, key: t.text}
+		}
+		obj.entries = append(obj.entries, entry)
+
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expectPunct("}"); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
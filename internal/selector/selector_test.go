@@ -0,0 +1,131 @@
+package selector
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const doc = `{
+  "kind": "List",
+  "items": [
+    {"metadata": {"name": "api", "namespace": "prod", "labels": {"team": "platform"}}, "tags": ["a", "b"]},
+    {"metadata": {"name": "web", "namespace": "dev", "labels": {}}, "tags": []}
+  ]
+}`
+
+func TestSelectorRecords(t *testing.T) {
+	var input any
+	if err := json.Unmarshal([]byte(doc), &input); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr   string
+		expect []map[string]any
+	}{
+		{
+			expr: ".items[] | {name: .metadata.name, ns: .metadata.namespace}",
+			expect: []map[string]any{
+				{"name": "api", "ns": "prod"},
+				{"name": "web", "ns": "dev"},
+			},
+		},
+		{
+			expr: ".items[].metadata | {name, team: .labels.team}",
+			expect: []map[string]any{
+				{"name": "api", "team": "platform"},
+				{"name": "web", "team": nil},
+			},
+		},
+		{
+			// arrays are expanded, so .items is the same as .items[]
+			expr: ".items[1].metadata",
+			expect: []map[string]any{
+				{"name": "web", "namespace": "dev", "labels": map[string]any{}},
+			},
+		},
+		{
+			expr: `.items[-1] | {"full name": .metadata["name"]}`,
+			expect: []map[string]any{
+				{"full name": "web"},
+			},
+		},
+		{
+			// an object per combination of outputs, like jq
+			expr: ".items[] | {name: .metadata.name, tag: .tags[]}",
+			expect: []map[string]any{
+				{"name": "api", "tag": "a"},
+				{"name": "api", "tag": "b"},
+			},
+		},
+		{
+			expr: "$.items[*].metadata.labels",
+			expect: []map[string]any{
+				{"team": "platform"},
+				{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			data, err := s.Records(input)
+			if err != nil {
+				t.Fatalf("Records() error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expect, data); diff != "" {
+				t.Errorf("Records() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelectorRecordsErrors(t *testing.T) {
+	var input any
+	if err := json.Unmarshal([]byte(doc), &input); err != nil {
+		t.Fatal(err)
+	}
+
+	exprs := []string{
+		".kind",         // not an object
+		".missing",      // null, i.e. a misspelled key
+		".missing[]",    // cannot iterate over null
+		".items.name",   // cannot index an array with a key
+		".items[].tags", // arrays of strings aren't records
+	}
+	for _, expr := range exprs {
+		t.Run(expr, func(t *testing.T) {
+			s, err := Parse(expr)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if _, err := s.Records(input); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	exprs := []string{
+		"items",
+		".items[",
+		"{name: }",
+		".items | ",
+		`."unterminated`,
+		".a..b",
+	}
+	for _, expr := range exprs {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := Parse(expr); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -1,13 +1,21 @@
 package sgen
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/scnewma/sgen/internal/fsutil"
 )
 
+// refreshTimeout is how long a background refresh is assumed to be running
+// for. After this a new refresh may be started even if the previous one never
+// finished.
+const refreshTimeout = 10 * time.Minute
+
 func CacheDir() (string, error) {
 	if dir := os.Getenv("SGEN_CACHE_DIR"); dir != "" {
 		return dir, nil
@@ -24,33 +32,135 @@ type SourceCache struct {
 	Dir string
 }
 
+// SourceMeta is stored next to a source's cached data.
+type SourceMeta struct {
+	SyncedAt time.Time `json:"synced_at"`
+	Count    int       `json:"count"`
+}
+
 func NewSourceCache() (*SourceCache, error) {
 	cacheDir, err := CacheDir()
 	if err != nil {
 		return nil, err
 	}
+	return SourceCacheIn(cacheDir), nil
+}
 
+// SourceCacheIn returns the cache of source data in cacheDir rather than the
+// default cache directory.
+func SourceCacheIn(cacheDir string) *SourceCache {
 	return &SourceCache{
 		Dir: filepath.Join(cacheDir, "sources", "by-name"),
-	}, nil
+	}
 }
 
-func (c *SourceCache) Store(name string, data []map[string]string) error {
+func (c *SourceCache) Store(name string, data []map[string]any) error {
 	path := filepath.Join(c.Dir, name+".json")
 	err := fsutil.WriteJSON(path, data)
 	if err != nil {
 		return fmt.Errorf("updating cache for %q: %w", name, err)
 	}
+
+	meta := SourceMeta{
+		SyncedAt: time.Now(),
+		Count:    len(data),
+	}
+	if err := fsutil.WriteJSON(c.metaPath(name), meta); err != nil {
+		return fmt.Errorf("updating cache metadata for %q: %w", name, err)
+	}
 	return nil
 }
 
-func (c *SourceCache) Load(name string) ([]map[string]string, error) {
+func (c *SourceCache) Load(name string) ([]map[string]any, error) {
 	path := filepath.Join(c.Dir, name+".json")
 
-	var data []map[string]string
+	var data []map[string]any
 	err := fsutil.ReadJSON(path, &data)
 	if err != nil {
 		return nil, fmt.Errorf("reading cache for %q: %w", name, err)
 	}
 	return data, nil
 }
+
+// Meta returns the metadata from the last time name was stored. An error
+// wrapping fs.ErrNotExist is returned if name has never been stored.
+func (c *SourceCache) Meta(name string) (*SourceMeta, error) {
+	var meta SourceMeta
+	if err := fsutil.ReadJSON(c.metaPath(name), &meta); err != nil {
+		return nil, fmt.Errorf("reading cache metadata for %q: %w", name, err)
+	}
+	return &meta, nil
+}
+
+// StartRefresh marks name as being refreshed. It returns false if another
+// refresh of name is already in progress.
+func (c *SourceCache) StartRefresh(name string) (bool, error) {
+	path := c.refreshPath(name)
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < refreshTimeout {
+		return false, nil
+	}
+
+	if err := fsutil.EnsureDirExists(c.Dir); err != nil {
+		return false, err
+	}
+	// the marker may exist from a refresh that never finished, so we
+	// overwrite it rather than failing if it exists
+	f, err := os.Create(path)
+	if err != nil {
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// FinishRefresh clears the mark left by StartRefresh.
+func (c *SourceCache) FinishRefresh(name string) error {
+	err := os.Remove(c.refreshPath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// DeferRefresh marks name as refreshed just now, so that no background refresh
+// of it is started until refreshTimeout has passed. It is used when a refresh
+// fails, to back off from a source that can't currently be synced.
+func (c *SourceCache) DeferRefresh(name string) error {
+	if err := fsutil.EnsureDirExists(c.Dir); err != nil {
+		return err
+	}
+	path := c.refreshPath(name)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// creating a file that already exists doesn't always update its mtime
+	now := time.Now()
+	return os.Chtimes(path, now, now)
+}
+
+// Lock takes an exclusive lock on name, which is held while its cache is
+// updated.
+func (c *SourceCache) Lock(name string) (*fsutil.Lock, error) {
+	return fsutil.LockExclusive(c.lockPath(name))
+}
+
+// RLock takes a shared lock on name, which is held while output is generated
+// from its cache so that the cache isn't updated part way through.
+func (c *SourceCache) RLock(name string) (*fsutil.Lock, error) {
+	return fsutil.LockShared(c.lockPath(name))
+}
+
+func (c *SourceCache) metaPath(name string) string {
+	return filepath.Join(c.Dir, name+".meta.json")
+}
+
+func (c *SourceCache) refreshPath(name string) string {
+	return filepath.Join(c.Dir, name+".refresh")
+}
+
+func (c *SourceCache) lockPath(name string) string {
+	return filepath.Join(c.Dir, name+".lock")
+}
@@ -0,0 +1,272 @@
+package sgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Item is a record as an entry in a launcher's list, i.e. an Alfred script
+// filter item.
+type Item struct {
+	UID          string
+	Title        string
+	Subtitle     string
+	Arg          string
+	Autocomplete string
+	Icon         string
+}
+
+// ItemTemplates are go templates for the fields of an Item. Empty templates
+// leave the field to its default, see ItemRenderer.Render.
+type ItemTemplates struct {
+	UID          string
+	Title        string
+	Subtitle     string
+	Arg          string
+	Autocomplete string
+	Icon         string
+}
+
+// ItemRenderer renders records as Items.
+type ItemRenderer struct {
+	id     string
+	fields []itemField
+}
+
+type itemField struct {
+	rndr *GoTemplateRenderer
+	set  func(*Item, string)
+}
+
+func NewItemRenderer(t ItemTemplates) (*ItemRenderer, error) {
+	templates := []struct {
+		name string
+		tmpl string
+		set  func(*Item, string)
+	}{
+		{"uid", t.UID, func(i *Item, s string) { i.UID = s }},
+		{"title", t.Title, func(i *Item, s string) { i.Title = s }},
+		{"subtitle", t.Subtitle, func(i *Item, s string) { i.Subtitle = s }},
+		{"arg", t.Arg, func(i *Item, s string) { i.Arg = s }},
+		{"autocomplete", t.Autocomplete, func(i *Item, s string) { i.Autocomplete = s }},
+		{"icon", t.Icon, func(i *Item, s string) { i.Icon = s }},
+	}
+
+	r := &ItemRenderer{}
+	var id strings.Builder
+	for _, tmpl := range templates {
+		if tmpl.tmpl == "" {
+			continue
+		}
+		rndr, err := NewGoTemplateRenderer(tmpl.tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tmpl.name, err)
+		}
+		r.fields = append(r.fields, itemField{rndr: rndr, set: tmpl.set})
+		fmt.Fprintf(&id, "\x00%s:%s", tmpl.name, tmpl.tmpl)
+	}
+	r.id = id.String()
+	return r, nil
+}
+
+// ID identifies the renderer's templates, see Renderer.ID.
+func (r *ItemRenderer) ID() string {
+	return r.id
+}
+
+// Render renders data as an Item. line is the record rendered by the source's
+// usual renderer, which is the title when there is no title template. The arg
+// defaults to the title.
+func (r *ItemRenderer) Render(data map[string]any, line string) (Item, error) {
+	item := Item{Title: line}
+	for _, f := range r.fields {
+		s, err := f.rndr.Render(data)
+		if err != nil {
+			return Item{}, err
+		}
+		f.set(&item, s)
+	}
+	if item.Arg == "" {
+		item.Arg = item.Title
+	}
+	return item, nil
+}
+
+// ListRenderer writes a complete list of items in the format of a launcher.
+// Unlike a Renderer it needs every record at once, since the list is a single
+// document.
+type ListRenderer interface {
+	ID() string
+	// ContentType is the media type of the list, for serving it over HTTP.
+	ContentType() string
+	RenderList(w io.Writer, items []Item) error
+}
+
+const (
+	jsonContentType = "application/json"
+	textContentType = "text/plain; charset=utf-8"
+)
+
+// ListFormats are the formats supported by NewListRenderer.
+var ListFormats = []string{"alfred", "raycast", "rofi", "dmenu"}
+
+// NewListRenderer returns the renderer for one of ListFormats.
+func NewListRenderer(format string) (ListRenderer, error) {
+	switch format {
+	case "alfred":
+		return &AlfredRenderer{}, nil
+	case "raycast":
+		return &RaycastRenderer{}, nil
+	case "rofi":
+		return &RofiRenderer{}, nil
+	case "dmenu":
+		return &DmenuRenderer{}, nil
+	}
+	return nil, fmt.Errorf("invalid output %q, valid outputs are [%s]", format, strings.Join(ListFormats, ","))
+}
+
+// AlfredRenderer writes an Alfred script filter document, see
+// https://www.alfredapp.com/help/workflows/inputs/script-filter/json/.
+type AlfredRenderer struct{}
+
+func (r *AlfredRenderer) ID() string {
+	return "<alfred>"
+}
+
+func (r *AlfredRenderer) ContentType() string {
+	return jsonContentType
+}
+
+func (r *AlfredRenderer) RenderList(w io.Writer, items []Item) error {
+	type icon struct {
+		Path string `json:"path"`
+	}
+	type alfredItem struct {
+		UID          string `json:"uid,omitempty"`
+		Title        string `json:"title"`
+		Subtitle     string `json:"subtitle,omitempty"`
+		Arg          string `json:"arg,omitempty"`
+		Autocomplete string `json:"autocomplete,omitempty"`
+		Icon         *icon  `json:"icon,omitempty"`
+	}
+
+	doc := struct {
+		Items []alfredItem `json:"items"`
+	}{Items: make([]alfredItem, 0, len(items))}
+	for _, item := range items {
+		ai := alfredItem{
+			UID:          item.UID,
+			Title:        item.Title,
+			Subtitle:     item.Subtitle,
+			Arg:          item.Arg,
+			Autocomplete: item.Autocomplete,
+		}
+		if item.Icon != "" {
+			ai.Icon = &icon{Path: item.Icon}
+		}
+		doc.Items = append(doc.Items, ai)
+	}
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// RaycastRenderer writes the items as a JSON document whose fields match the
+// props of Raycast's List.Item, for extensions that list sgen's output.
+type RaycastRenderer struct{}
+
+func (r *RaycastRenderer) ID() string {
+	return "<raycast>"
+}
+
+func (r *RaycastRenderer) ContentType() string {
+	return jsonContentType
+}
+
+func (r *RaycastRenderer) RenderList(w io.Writer, items []Item) error {
+	type raycastItem struct {
+		ID       string `json:"id,omitempty"`
+		Title    string `json:"title"`
+		Subtitle string `json:"subtitle,omitempty"`
+		Icon     string `json:"icon,omitempty"`
+		Arg      string `json:"arg,omitempty"`
+	}
+
+	doc := struct {
+		Items []raycastItem `json:"items"`
+	}{Items: make([]raycastItem, 0, len(items))}
+	for _, item := range items {
+		doc.Items = append(doc.Items, raycastItem{
+			ID:       item.UID,
+			Title:    item.Title,
+			Subtitle: item.Subtitle,
+			Icon:     item.Icon,
+			Arg:      item.Arg,
+		})
+	}
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// RofiRenderer writes a line per item for rofi's script mode. The arg is
+// passed back to the script as ROFI_INFO and the subtitle is searchable but
+// not shown, see rofi-script(5).
+type RofiRenderer struct{}
+
+func (r *RofiRenderer) ID() string {
+	return "<rofi>"
+}
+
+func (r *RofiRenderer) ContentType() string {
+	return textContentType
+}
+
+func (r *RofiRenderer) RenderList(w io.Writer, items []Item) error {
+	for _, item := range items {
+		var opts []string
+		if item.Icon != "" {
+			opts = append(opts, "icon", item.Icon)
+		}
+		if item.Arg != "" {
+			opts = append(opts, "info", item.Arg)
+		}
+		if item.Subtitle != "" {
+			opts = append(opts, "meta", item.Subtitle)
+		}
+
+		line := oneLine(item.Title)
+		if len(opts) > 0 {
+			line += "\x00" + strings.Join(opts, "\x1f")
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DmenuRenderer writes the title of each item on its own line, for dmenu and
+// anything else that reads a plain list.
+type DmenuRenderer struct{}
+
+func (r *DmenuRenderer) ID() string {
+	return "<dmenu>"
+}
+
+func (r *DmenuRenderer) ContentType() string {
+	return textContentType
+}
+
+func (r *DmenuRenderer) RenderList(w io.Writer, items []Item) error {
+	for _, item := range items {
+		if _, err := fmt.Fprintln(w, oneLine(item.Title)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// oneLine replaces newlines with spaces since each item must be a single line
+// in line based formats.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ").Replace(s)
+}
@@ -5,13 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
+	"github.com/scnewma/sgen/internal/records"
 )
 
 type Renderer interface {
 	ID() string
-	Render(map[string]string) (string, error)
+	Render(map[string]any) (string, error)
 }
 
 type JSONRenderer struct{}
@@ -20,7 +22,7 @@ func (r *JSONRenderer) ID() string {
 	return "<JSON>"
 }
 
-func (r *JSONRenderer) Render(data map[string]string) (string, error) {
+func (r *JSONRenderer) Render(data map[string]any) (string, error) {
 	buf, err := json.Marshal(data)
 	if err != nil {
 		return "", fmt.Errorf("rendering json: %w", err)
@@ -34,7 +36,18 @@ type GoTemplateRenderer struct {
 }
 
 func NewGoTemplateRenderer(tmpl string) (*GoTemplateRenderer, error) {
-	t, err := template.New("").Funcs(sprig.FuncMap()).Parse(tmpl)
+	return newGoTemplateRenderer(tmpl, sprig.FuncMap())
+}
+
+// NewHermeticGoTemplateRenderer parses tmpl with only the sprig functions
+// whose output depends on nothing but their arguments, so that templates from
+// untrusted callers can't read the environment or reach the network.
+func NewHermeticGoTemplateRenderer(tmpl string) (*GoTemplateRenderer, error) {
+	return newGoTemplateRenderer(tmpl, sprig.HermeticTxtFuncMap())
+}
+
+func newGoTemplateRenderer(tmpl string, funcs template.FuncMap) (*GoTemplateRenderer, error) {
+	t, err := template.New("").Funcs(funcs).Parse(tmpl)
 	if err != nil {
 		return nil, fmt.Errorf("invalid template %q: %w", tmpl, err)
 	}
@@ -48,9 +61,52 @@ func (r *GoTemplateRenderer) ID() string {
 	return r.tmplStr
 }
 
-func (r *GoTemplateRenderer) Render(data map[string]string) (string, error) {
+func (r *GoTemplateRenderer) Render(data map[string]any) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := r.tmpl.Execute(buf, records.NativeRecord(data)); err != nil {
+		return "", fmt.Errorf("rendering go template %q: %w", r.tmplStr, err)
+	}
+	return buf.String(), nil
+}
+
+// Document is the data a DocumentRenderer renders.
+type Document struct {
+	// Source is the name of the source the items are from.
+	Source string
+	Items  []map[string]any
+	Count  int
+	// SyncedAt is when the source was last synced, or nil if it isn't cached.
+	SyncedAt *time.Time
+}
+
+// DocumentRenderer renders all of a source's records at once with a go
+// template, i.e. to wrap them in a header and footer or output a table.
+type DocumentRenderer struct {
+	tmplStr string
+	tmpl    *template.Template
+}
+
+func NewDocumentRenderer(tmpl string) (*DocumentRenderer, error) {
+	t, err := template.New("").Funcs(sprig.FuncMap()).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %q: %w", tmpl, err)
+	}
+	return &DocumentRenderer{
+		tmplStr: tmpl,
+		tmpl:    t,
+	}, nil
+}
+
+// ID identifies the template, see Renderer.ID. It can't collide with the ID
+// of a GoTemplateRenderer for the same template text.
+func (r *DocumentRenderer) ID() string {
+	return "<document>" + r.tmplStr
+}
+
+func (r *DocumentRenderer) Render(doc Document) (string, error) {
+	doc.Items = records.NativeNumbers(doc.Items).([]map[string]any)
 	buf := new(bytes.Buffer)
-	if err := r.tmpl.Execute(buf, data); err != nil {
+	if err := r.tmpl.Execute(buf, doc); err != nil {
 		return "", fmt.Errorf("rendering go template %q: %w", r.tmplStr, err)
 	}
 	return buf.String(), nil
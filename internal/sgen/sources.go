@@ -1,24 +1,74 @@
 package sgen
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/scnewma/sgen/internal/fsutil"
+)
 
 type Supplier interface {
 	ShouldCache() bool
-	Supply(context.Context) ([]map[string]string, error)
+	Supply(context.Context) ([]map[string]any, error)
+}
+
+// ModifiedSupplier is a Supplier that isn't cached but can tell when its data
+// last changed, i.e. a file, so that sources derived from it know when to
+// expire.
+type ModifiedSupplier interface {
+	Supplier
+	ModifiedAt() (time.Time, error)
+}
+
+// TimeoutError is returned by a Supplier that took longer than it is allowed
+// to. Source fills in its name so every timeout says which source it was.
+type TimeoutError struct {
+	// Source is the name of the source that timed out, when it is known.
+	Source  string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("timed out after %s", e.Timeout)
+	}
+	return fmt.Sprintf("source %q timed out after %s", e.Source, e.Timeout)
 }
 
 type Source struct {
 	Name      string
 	Supplier  Supplier
 	Renderers map[string]Renderer
+	// Documents are the source's named templates that render all of its
+	// records at once. Names are unique across Renderers and Documents.
+	Documents map[string]*DocumentRenderer
+	// TTL is how long the source's cached data is considered fresh for. Zero
+	// means the cached data never expires.
+	TTL time.Duration
+	// SortBy are the fields the source's records are sorted by, before any
+	// order requested when generating output is applied.
+	SortBy []string
+	// Items renders the source's records as list items, i.e. for Alfred. When
+	// nil items are titled with the source's rendered records.
+	Items *ItemRenderer
+	// Inputs are the sources this source's data is derived from, i.e. the
+	// sources of a join. The source expires when any of them have changed
+	// since it was synced, see Expired.
+	Inputs []*Source
+	// CacheDir is the directory the source's data is cached in, CacheDir()
+	// when empty.
+	CacheDir string
 }
 
-func (s *Source) Load(ctx context.Context) ([]map[string]string, error) {
+func (s *Source) Load(ctx context.Context) ([]map[string]any, error) {
 	if !s.Supplier.ShouldCache() {
-		return s.Supplier.Supply(ctx)
+		return s.supply(ctx)
 	}
 
-	cache, err := NewSourceCache()
+	cache, err := s.cache()
 	if err != nil {
 		return nil, err
 	}
@@ -26,21 +76,135 @@ func (s *Source) Load(ctx context.Context) ([]map[string]string, error) {
 	return cache.Load(s.Name)
 }
 
-// Sync updates the source's cache with the latest values from it's supplier.
-func (s *Source) Sync(ctx context.Context) error {
-	if !s.Supplier.ShouldCache() {
-		return nil
+// Sync updates the source's cache with the latest values from it's supplier
+// and returns the number of items that were cached. If invalidate is given it
+// is called with the source locked once the cache has been updated, to clear
+// anything derived from the previous data.
+func (s *Source) Sync(ctx context.Context, invalidate func() error) (int, error) {
+	cache, err := s.cache()
+	if err != nil {
+		return 0, err
+	}
+
+	var data []map[string]any
+	if s.Supplier.ShouldCache() {
+		// the supplier isn't run with the lock held since it may be slow and
+		// readers can keep using the previous data in the meantime
+		data, err = s.supply(ctx)
+		if err != nil {
+			// otherwise a source that keeps failing would be refreshed in the
+			// background again by every run that finds it expired
+			_ = cache.DeferRefresh(s.Name)
+			return 0, err
+		}
+
+		// a failure to clear the refresh mark only delays the next background
+		// refresh, so it isn't worth failing the sync over
+		defer func() { _ = cache.FinishRefresh(s.Name) }()
+	}
+
+	lock, err := cache.Lock(s.Name)
+	if err != nil {
+		return 0, fmt.Errorf("locking cache for %q: %w", s.Name, err)
 	}
+	defer lock.Unlock()
 
+	if s.Supplier.ShouldCache() {
+		if err := cache.Store(s.Name, data); err != nil {
+			return 0, err
+		}
+	}
+	if invalidate != nil {
+		if err := invalidate(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// cache returns the cache of the source's data.
+func (s *Source) cache() (*SourceCache, error) {
+	if s.CacheDir == "" {
+		return NewSourceCache()
+	}
+	return SourceCacheIn(s.CacheDir), nil
+}
+
+// supply runs the source's supplier, naming the source in timeouts.
+func (s *Source) supply(ctx context.Context) ([]map[string]any, error) {
 	data, err := s.Supplier.Supply(ctx)
+	var timeout *TimeoutError
+	if errors.As(err, &timeout) && timeout.Source == "" {
+		return nil, &TimeoutError{Source: s.Name, Timeout: timeout.Timeout}
+	}
+	return data, err
+}
+
+// RLock takes a shared lock on the source's cache, see SourceCache.RLock.
+func (s *Source) RLock() (*fsutil.Lock, error) {
+	cache, err := s.cache()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return cache.RLock(s.Name)
+}
 
-	cache, err := NewSourceCache()
+// SyncedAt returns when the source was last synced. An error wrapping
+// fs.ErrNotExist is returned if the source has never been synced.
+func (s *Source) SyncedAt() (time.Time, error) {
+	cache, err := s.cache()
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
 
-	return cache.Store(s.Name, data)
+	meta, err := cache.Meta(s.Name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return meta.SyncedAt, nil
+}
+
+// Expired reports whether the source's cached data is older than its TTL or
+// than the data of any of its inputs. Sources that are not cached, or have no
+// TTL and no inputs, never expire. Inputs that aren't cached change when
+// their ModifiedSupplier says they were modified or, if they can't tell, all
+// the time, so the source is always expired.
+func (s *Source) Expired() (bool, error) {
+	if !s.Supplier.ShouldCache() || (s.TTL == 0 && len(s.Inputs) == 0) {
+		return false, nil
+	}
+
+	syncedAt, err := s.SyncedAt()
+	if err != nil {
+		return false, err
+	}
+	if s.TTL > 0 && time.Since(syncedAt) > s.TTL {
+		return true, nil
+	}
+
+	for _, input := range s.Inputs {
+		changedAt, err := input.changedAt()
+		if errors.Is(err, fs.ErrNotExist) {
+			// inputs that have never been synced have no sync time to compare
+			// against
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		if changedAt.After(syncedAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// changedAt returns when the source's data last changed, see Expired.
+func (s *Source) changedAt() (time.Time, error) {
+	if s.Supplier.ShouldCache() {
+		return s.SyncedAt()
+	}
+	if modified, ok := s.Supplier.(ModifiedSupplier); ok {
+		return modified.ModifiedAt()
+	}
+	return time.Now(), nil
 }
@@ -1,53 +1,292 @@
 package supply
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/scnewma/sgen/internal/encoding"
+	"github.com/scnewma/sgen/internal/selector"
+	"github.com/scnewma/sgen/internal/sgen"
 )
 
+type CommandOptions struct {
+	// Args is the command as a list of arguments, which is run directly
+	// without any parsing. It is used when the command string is empty.
+	Args []string
+	// Shell is the shell that commands starting with ! are run with, "sh"
+	// when empty. The command is passed to it with -c.
+	Shell string
+	// Format is the format of the command's output, either "json" (the
+	// default) for an array of objects or "jsonl" for one object per line.
+	Format string
+	// Select picks the records out of the command's output, see
+	// selector.Selector. With the jsonl format it is run on every line.
+	Select string
+	// Env are environment variables set for the command, in addition to
+	// sgen's own environment unless InheritEnv is false.
+	Env map[string]string
+	// InheritEnv is whether the command inherits sgen's environment, which
+	// it does when nil. The command is still found using sgen's PATH.
+	InheritEnv *bool
+	// WorkingDir is the directory the command is run in, sgen's working
+	// directory when empty.
+	WorkingDir string
+	// Stdin is written to the command's stdin.
+	Stdin string
+	// StdinFile is a file that is written to the command's stdin. Only one of
+	// Stdin and StdinFile can be set.
+	StdinFile string
+	// Timeout is how long the command can run for before it is killed, zero
+	// for no limit.
+	Timeout time.Duration
+}
+
+// commandWaitDelay is how long the output of a killed command is read for,
+// i.e. while processes it started exit, before it is abandoned.
+const commandWaitDelay = time.Second
+
 type Command struct {
 	argv []string
+	opts CommandOptions
+	sel  *selector.Selector
 }
 
-func NewCommandSupply(cmd string) (*Command, error) {
+func NewCommandSupply(cmd string, opts CommandOptions) (*Command, error) {
 	var argv []string
-	if strings.HasPrefix(cmd, "!") {
+	switch {
+	case cmd != "" && len(opts.Args) > 0:
+		return nil, fmt.Errorf("only one of command and args can be set")
+	case len(opts.Args) > 0:
+		if opts.Shell != "" {
+			return nil, fmt.Errorf("shell can only be set for commands starting with !")
+		}
+		argv = opts.Args
+	case strings.HasPrefix(cmd, "!"):
 		cmd = strings.TrimPrefix(cmd, "!")
 		if cmd == "" {
 			return nil, fmt.Errorf("no command given")
 		}
 
-		argv = []string{"sh", "-c", cmd}
-	} else {
-		argv = strings.Fields(cmd)
-		if len(argv) == 0 {
-			return nil, fmt.Errorf("no command given")
+		shell := opts.Shell
+		if shell == "" {
+			shell = "sh"
+		}
+		argv = []string{shell, "-c", cmd}
+	default:
+		if opts.Shell != "" {
+			return nil, fmt.Errorf("shell can only be set for commands starting with !")
 		}
+		var err error
+		if argv, err = splitCommand(cmd); err != nil {
+			return nil, err
+		}
+	}
+	if len(argv) == 0 || argv[0] == "" {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	switch opts.Format {
+	case "":
+		opts.Format = "json"
+	case "json", "jsonl":
+	default:
+		return nil, fmt.Errorf("invalid format %q, valid formats are [json,jsonl]", opts.Format)
+	}
+
+	if opts.Stdin != "" && opts.StdinFile != "" {
+		return nil, fmt.Errorf("only one of stdin and stdin_file can be set")
+	}
+	if opts.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative")
 	}
 
-	return &Command{argv}, nil
+	sel, err := parseSelect(opts.Select)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Command{argv: argv, opts: opts, sel: sel}, nil
 }
 
-func (s *Command) Supply(ctx context.Context) ([]map[string]string, error) {
+func (s *Command) Supply(parent context.Context) ([]map[string]any, error) {
+	ctx := parent
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, s.opts.Timeout)
+		defer cancel()
+	}
+
 	cmd := exec.CommandContext(ctx, s.argv[0], s.argv[1:]...)
-	out, err := cmd.Output()
-	if err != nil {
+	cmd.Dir = s.opts.WorkingDir
+	cmd.Env = s.env()
+	cmd.WaitDelay = commandWaitDelay
+	switch {
+	case s.opts.Stdin != "":
+		cmd.Stdin = strings.NewReader(s.opts.Stdin)
+	case s.opts.StdinFile != "":
+		f, err := os.Open(s.opts.StdinFile)
+		if err != nil {
+			return nil, fmt.Errorf("opening stdin_file: %w", err)
+		}
+		defer f.Close()
+		cmd.Stdin = f
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// stdout is closed once the command has been waited for, rather than
+	// when everything holding it open has exited, so a process left behind
+	// by a killed shell command can't block decoding
+	stdout, stdoutW := io.Pipe()
+	cmd.Stdout = stdoutW
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	waitErr := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		stdoutW.Close()
+		waitErr <- err
+	}()
+
+	data, decodeErr := s.decode(stdout)
+	// the command has to be able to finish writing its output before it can
+	// exit, even if we've stopped decoding it
+	_, _ = io.Copy(io.Discard, stdout)
+
+	if err := <-waitErr; err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+			return nil, &sgen.TimeoutError{Timeout: s.opts.Timeout}
+		}
 		var exitError *exec.ExitError
 		if errors.As(err, &exitError) {
 			cmdStr := strings.Join(s.argv, " ")
-			return nil, fmt.Errorf("running command %q: stderr: %s", cmdStr, string(exitError.Stderr))
+			return nil, fmt.Errorf("running command %q: stderr: %s", cmdStr, strings.TrimSpace(stderr.String()))
 		}
 		return nil, err
 	}
-	var data []map[string]string
-	err = json.Unmarshal(out, &data)
-	return data, err
+	if decodeErr != nil {
+		return nil, fmt.Errorf("decoding output of %q: %w", strings.Join(s.argv, " "), decodeErr)
+	}
+	return data, nil
+}
+
+func (s *Command) decode(r io.Reader) ([]map[string]any, error) {
+	if s.opts.Format == "jsonl" {
+		return decodeJSONLines(r, s.sel)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := encoding.DecodeJSON(out, &v); err != nil {
+		return nil, err
+	}
+	return toRecords(v, s.sel)
+}
+
+// env returns the command's environment, nil to inherit sgen's.
+func (s *Command) env() []string {
+	inherit := s.opts.InheritEnv == nil || *s.opts.InheritEnv
+	if inherit && len(s.opts.Env) == 0 {
+		return nil
+	}
+
+	env := []string{}
+	if inherit {
+		env = os.Environ()
+	}
+	keys := make([]string, 0, len(s.opts.Env))
+	for k := range s.opts.Env {
+		keys = append(keys, k)
+	}
+	// later values take precedence, so the inherited ones are overridden
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+s.opts.Env[k])
+	}
+	return env
+}
+
+// Executable returns the name or path of the program the command runs.
+func (s *Command) Executable() string {
+	return s.argv[0]
 }
 
 func (s *Command) ShouldCache() bool {
 	return true
 }
+
+// splitCommand splits cmd into arguments the way a POSIX shell does, without
+// any expansions. Single quotes preserve everything up to the next single
+// quote, double quotes preserve everything but a backslash before $, `, " or
+// \, and a backslash outside of quotes preserves the next character.
+func splitCommand(cmd string) ([]string, error) {
+	var (
+		args  []string
+		arg   strings.Builder
+		inArg bool
+	)
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case c == '\\':
+			if i+1 == len(cmd) {
+				return nil, fmt.Errorf("command ends with an unescaped backslash")
+			}
+			i++
+			// a backslash before a newline continues the line
+			if cmd[i] != '\n' {
+				arg.WriteByte(cmd[i])
+				inArg = true
+			}
+		case c == '\'':
+			inArg = true
+			end := strings.IndexByte(cmd[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in command")
+			}
+			arg.WriteString(cmd[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			inArg = true
+			for i++; ; i++ {
+				if i == len(cmd) {
+					return nil, fmt.Errorf("unterminated double quote in command")
+				}
+				if cmd[i] == '"' {
+					break
+				}
+				if cmd[i] == '\\' && i+1 < len(cmd) && strings.IndexByte("$`\"\\\n", cmd[i+1]) >= 0 {
+					i++
+					if cmd[i] == '\n' {
+						continue
+					}
+				}
+				arg.WriteByte(cmd[i])
+			}
+		default:
+			inArg = true
+			arg.WriteByte(c)
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,251 @@
+package supply
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/scnewma/sgen/internal/sgen"
+)
+
+func TestCommandSync(t *testing.T) {
+	expect := []map[string]any{
+		{"name": "bob"},
+		{"name": "alice"},
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		opts    CommandOptions
+	}{
+		{name: "json", command: "cat testdata/people.json"},
+		{name: "jsonl", command: "cat testdata/people.jsonl", opts: CommandOptions{Format: "jsonl"}},
+		{name: "shell", command: "!cat testdata/people.jsonl | cat", opts: CommandOptions{Format: "jsonl"}},
+		{name: "select", command: "cat testdata/people-wrapped.json", opts: CommandOptions{Select: ".data.people[] | {name: .first}"}},
+		{name: "select jsonl", command: "cat testdata/people-wrapped.jsonl", opts: CommandOptions{Format: "jsonl", Select: ".person"}},
+		{name: "quoted", command: `cat "testdata/"'people.jsonl'`, opts: CommandOptions{Format: "jsonl"}},
+		{name: "args", opts: CommandOptions{Args: []string{"cat", "testdata/people.json"}}},
+		{name: "shell option", command: "!cat testdata/people.jsonl | cat", opts: CommandOptions{Format: "jsonl", Shell: "bash"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewCommandSupply(tt.command, tt.opts)
+			if err != nil {
+				t.Fatalf("NewCommandSupply() error: %v", err)
+			}
+			data, err := s.Supply(context.Background())
+			if err != nil {
+				t.Fatalf("command sync error: %v", err)
+			}
+
+			if diff := cmp.Diff(expect, data); diff != "" {
+				t.Errorf("Command.Sync() data mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCommandSyncNull(t *testing.T) {
+	for _, opts := range []CommandOptions{{}, {Select: ".items"}} {
+		s, err := NewCommandSupply("echo null", opts)
+		if err != nil {
+			t.Fatalf("NewCommandSupply() error: %v", err)
+		}
+		data, err := s.Supply(context.Background())
+		if err != nil {
+			t.Fatalf("Command.Supply() error: %v", err)
+		}
+		if diff := cmp.Diff([]map[string]any{}, data); diff != "" {
+			t.Errorf("Command.Supply() data mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestCommandSyncErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		opts    CommandOptions
+		expect  string
+	}{
+		{
+			name:    "malformed line",
+			command: "cat testdata/malformed.jsonl",
+			opts:    CommandOptions{Format: "jsonl"},
+			expect:  "line 2",
+		},
+		{
+			name:    "not an array",
+			command: "cat testdata/people-wrapped.json",
+			expect:  "use select",
+		},
+		{
+			// only a null document is no records, not a null selection
+			name:    "misspelled select key",
+			command: "cat testdata/people-wrapped.json",
+			opts:    CommandOptions{Select: ".itmes"},
+			expect:  "selected null",
+		},
+		{
+			name:    "misspelled select key iterated",
+			command: "cat testdata/people-wrapped.json",
+			opts:    CommandOptions{Select: ".itmes[]"},
+			expect:  "cannot iterate over null",
+		},
+		{
+			name:    "failed command",
+			command: "!echo oops >&2; exit 1",
+			expect:  "stderr: oops",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewCommandSupply(tt.command, tt.opts)
+			if err != nil {
+				t.Fatalf("NewCommandSupply() error: %v", err)
+			}
+			_, err = s.Supply(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.expect) {
+				t.Errorf("expected an error containing %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
+
+func TestCommandOptions(t *testing.T) {
+	t.Setenv("SGEN_TEST_INHERITED", "inherited")
+	noInherit := false
+
+	tests := []struct {
+		name    string
+		command string
+		opts    CommandOptions
+		expect  []map[string]any
+	}{
+		{
+			name:    "env",
+			command: `!printf '[{"set": "%s", "inherited": "%s"}]' "$SGEN_TEST_SET" "$SGEN_TEST_INHERITED"`,
+			opts:    CommandOptions{Env: map[string]string{"SGEN_TEST_SET": "set"}},
+			expect:  []map[string]any{{"set": "set", "inherited": "inherited"}},
+		},
+		{
+			name:    "without inherited env",
+			command: `!printf '[{"set": "%s", "inherited": "%s"}]' "$SGEN_TEST_SET" "$SGEN_TEST_INHERITED"`,
+			opts:    CommandOptions{Env: map[string]string{"SGEN_TEST_SET": "set"}, InheritEnv: &noInherit},
+			expect:  []map[string]any{{"set": "set", "inherited": ""}},
+		},
+		{
+			name:    "working dir",
+			command: "cat people.json",
+			opts:    CommandOptions{WorkingDir: "testdata"},
+			expect:  []map[string]any{{"name": "bob"}, {"name": "alice"}},
+		},
+		{
+			name:    "stdin",
+			command: "cat",
+			opts:    CommandOptions{Stdin: `[{"name": "stdin"}]`},
+			expect:  []map[string]any{{"name": "stdin"}},
+		},
+		{
+			name:    "stdin file",
+			command: "cat",
+			opts:    CommandOptions{StdinFile: "testdata/people.json"},
+			expect:  []map[string]any{{"name": "bob"}, {"name": "alice"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewCommandSupply(tt.command, tt.opts)
+			if err != nil {
+				t.Fatalf("NewCommandSupply() error: %v", err)
+			}
+			data, err := s.Supply(context.Background())
+			if err != nil {
+				t.Fatalf("Command.Supply() error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expect, data); diff != "" {
+				t.Errorf("Command.Supply() data mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	// the shell's child holds stdout open after the shell is killed
+	s, err := NewCommandSupply("!sleep 10; echo '[]'", CommandOptions{Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewCommandSupply() error: %v", err)
+	}
+
+	start := time.Now()
+	_, err = s.Supply(context.Background())
+	var timeout *sgen.TimeoutError
+	if !errors.As(err, &timeout) || timeout.Timeout != 100*time.Millisecond {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the command to be abandoned, took %s", elapsed)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		expect  []string
+	}{
+		{command: "gh repo list", expect: []string{"gh", "repo", "list"}},
+		{command: "  gh\trepo\n list ", expect: []string{"gh", "repo", "list"}},
+		{command: `gh api -q '.[] | .name' /orgs/x/repos`, expect: []string{"gh", "api", "-q", ".[] | .name", "/orgs/x/repos"}},
+		{command: `echo "a \"b\" \$HOME \n"`, expect: []string{"echo", `a "b" $HOME \n`}},
+		{command: `echo 'it'\''s' a\ b`, expect: []string{"echo", "it's", "a b"}},
+		{command: `echo '' ""`, expect: []string{"echo", "", ""}},
+		{command: "echo a \\\n b", expect: []string{"echo", "a", "b"}},
+		{command: `echo $HOME ~ *`, expect: []string{"echo", "$HOME", "~", "*"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			args, err := splitCommand(tt.command)
+			if err != nil {
+				t.Fatalf("splitCommand() error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expect, args); diff != "" {
+				t.Errorf("splitCommand() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewCommandSupplyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		opts    CommandOptions
+		expect  string
+	}{
+		{name: "empty", command: "", expect: "no command given"},
+		{name: "single quote", command: "echo 'a", expect: "unterminated single quote"},
+		{name: "double quote", command: `echo "a`, expect: "unterminated double quote"},
+		{name: "backslash", command: `echo a\`, expect: "unescaped backslash"},
+		{name: "command and args", command: "echo", opts: CommandOptions{Args: []string{"echo"}}, expect: "only one of command and args"},
+		{name: "shell without !", command: "echo", opts: CommandOptions{Shell: "bash"}, expect: "commands starting with !"},
+		{name: "both stdin", command: "cat", opts: CommandOptions{Stdin: "[]", StdinFile: "testdata/people.json"}, expect: "only one of stdin and stdin_file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCommandSupply(tt.command, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.expect) {
+				t.Errorf("expected error containing %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
@@ -1,31 +1,107 @@
 package supply
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/scnewma/sgen/internal/encoding"
 	"github.com/scnewma/sgen/internal/fsutil"
+	"github.com/scnewma/sgen/internal/selector"
 	"gopkg.in/yaml.v3"
 )
 
+type FileOptions struct {
+	// Format is one of "json", "jsonl", "yaml", "csv" or "tsv". When empty it
+	// is determined by the file's extension.
+	Format string
+	// Delimiter separates the fields of csv files, it defaults to "," for csv
+	// and a tab for tsv.
+	Delimiter string
+	// HasHeader is whether the first row of a csv or tsv file holds the field
+	// names, which is the default.
+	HasHeader *bool
+	// Columns are the field names of each row of a csv or tsv file. They are
+	// required when there is no header row and take precedence over it when
+	// there is.
+	Columns []string
+	// Select picks the records out of the file, see selector.Selector. With
+	// the jsonl, csv and tsv formats it is run on every line or row.
+	Select string
+}
+
 type File struct {
 	path string
+	opts FileOptions
+	sel  *selector.Selector
 }
 
-func NewFileSupply(path string) (*File, error) {
+func NewFileSupply(path string, opts FileOptions) (*File, error) {
 	if !fsutil.Exists(path) {
 		return nil, fmt.Errorf("file not found: %q", path)
 	}
-	return &File{path}, nil
+
+	if opts.Format == "" {
+		ext := filepath.Ext(path)
+		if ext == "" {
+			return nil, fmt.Errorf("cannot determine encoding for file %q because there is no extension, set the format", path)
+		}
+		// ext[1:] trims the leading "."
+		switch ext[1:] {
+		case "json":
+			opts.Format = "json"
+		case "jsonl", "ndjson":
+			opts.Format = "jsonl"
+		case "yml", "yaml":
+			opts.Format = "yaml"
+		case "csv":
+			opts.Format = "csv"
+		case "tsv":
+			opts.Format = "tsv"
+		default:
+			return nil, fmt.Errorf("unsupported file extension %q, set the format", ext)
+		}
+	}
+
+	switch opts.Format {
+	case "json", "jsonl", "yaml":
+		if opts.Delimiter != "" || opts.HasHeader != nil || len(opts.Columns) > 0 {
+			return nil, fmt.Errorf("delimiter, has_header and columns are only supported for csv and tsv files")
+		}
+	case "csv", "tsv":
+		if opts.Delimiter != "" && utf8.RuneCountInString(opts.Delimiter) != 1 {
+			return nil, fmt.Errorf("delimiter %q must be a single character", opts.Delimiter)
+		}
+		if opts.HasHeader != nil && !*opts.HasHeader && len(opts.Columns) == 0 {
+			return nil, fmt.Errorf("columns are required for files without a header")
+		}
+		if err := checkColumns(opts.Columns); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid format %q, valid formats are [json,jsonl,yaml,csv,tsv]", opts.Format)
+	}
+
+	sel, err := parseSelect(opts.Select)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{path: path, opts: opts, sel: sel}, nil
 }
 
-func (s *File) Supply(_ context.Context) ([]map[string]string, error) {
-	ext := filepath.Ext(s.path)
-	if ext == "" {
-		return nil, fmt.Errorf("cannot determine encoding for file %q because there is no extension", s.path)
+func (s *File) Supply(_ context.Context) ([]map[string]any, error) {
+	if s.opts.Format == "jsonl" {
+		return s.decodeJSONLines()
 	}
 
 	contents, err := os.ReadFile(s.path)
@@ -33,15 +109,22 @@ func (s *File) Supply(_ context.Context) ([]map[string]string, error) {
 		return nil, fmt.Errorf("cannot read %q: %w", s.path, err)
 	}
 
-	var data []map[string]string
-	// ext[1:] trims the leading "."
-	switch ext[1:] {
+	var data []map[string]any
+	switch s.opts.Format {
 	case "json":
-		err = json.Unmarshal(contents, &data)
-	case "yml", "yaml":
-		err = yaml.Unmarshal(contents, &data)
+		var v any
+		if err = encoding.DecodeJSON(contents, &v); err == nil {
+			data, err = toRecords(v, s.sel)
+		}
+	case "yaml":
+		var v any
+		if err = decodeYAML(contents, &v); err == nil {
+			data, err = toRecords(v, s.sel)
+		}
+	case "csv", "tsv":
+		data, err = s.decodeCSV(contents)
 	default:
-		return nil, fmt.Errorf("unsupported file extension %q", ext)
+		return nil, fmt.Errorf("unsupported format %q", s.opts.Format)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("decoding %q: %w", s.path, err)
@@ -55,3 +138,130 @@ func (s *File) ShouldCache() bool {
 	// anyway, it makes it less work to just read the source
 	return false
 }
+
+// ModifiedAt returns the file's modification time, so that sources derived
+// from it expire when it changes.
+func (s *File) ModifiedAt() (time.Time, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot read %q: %w", s.path, err)
+	}
+	return info.ModTime(), nil
+}
+
+// decodeJSONLines decodes the file as it's read rather than reading it into
+// memory first, since JSON Lines files are often large.
+func (s *File) decodeJSONLines() ([]map[string]any, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", s.path, err)
+	}
+	defer f.Close()
+
+	data, err := decodeJSONLines(f, s.sel)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %q: %w", s.path, err)
+	}
+	return data, nil
+}
+
+// decodeYAML decodes YAML into v by way of JSON so that the decoded values have
+// the same types as JSON data would (i.e. json.Number numbers, RFC 3339 strings
+// for timestamps), which keeps templates behaving the same regardless of the
+// format the source data was in.
+func decodeYAML(contents []byte, v any) error {
+	var raw any
+	if err := yaml.Unmarshal(contents, &raw); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return encoding.DecodeJSON(buf, v)
+}
+
+// utf8BOM is the byte order mark that spreadsheets such as Excel write at the
+// start of UTF-8 csv files.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// decodeCSV decodes each row into a record keyed by the column names. Every
+// value is a string since csv has no types.
+func (s *File) decodeCSV(contents []byte) ([]map[string]any, error) {
+	// the BOM would otherwise end up in the name of the first column
+	contents = bytes.TrimPrefix(contents, utf8BOM)
+	r := csv.NewReader(bytes.NewReader(contents))
+	r.FieldsPerRecord = -1
+	if s.opts.Format == "tsv" {
+		r.Comma = '\t'
+		// tsv files rarely quote fields, so allow quotes within a value
+		r.LazyQuotes = true
+	}
+	if s.opts.Delimiter != "" {
+		r.Comma, _ = utf8.DecodeRuneInString(s.opts.Delimiter)
+	}
+
+	columns := s.opts.Columns
+	if s.opts.HasHeader == nil || *s.opts.HasHeader {
+		header, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return []map[string]any{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			columns = header
+			if err := checkColumns(columns); err != nil {
+				return nil, fmt.Errorf("header: %w", err)
+			}
+		}
+	}
+
+	data := []map[string]any{}
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(row) > len(columns) {
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %d fields, but there are only %d columns", line, len(row), len(columns))
+		}
+
+		// missing trailing fields are empty, so every record has every column
+		record := make(map[string]any, len(columns))
+		for i, column := range columns {
+			record[column] = ""
+			if i < len(row) {
+				record[column] = row[i]
+			}
+		}
+		if s.sel == nil {
+			data = append(data, record)
+			continue
+		}
+		selected, err := s.sel.Records(record)
+		if err != nil {
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		data = append(data, selected...)
+	}
+	return data, nil
+}
+
+func checkColumns(columns []string) error {
+	seen := make(map[string]bool, len(columns))
+	for i, column := range columns {
+		if strings.TrimSpace(column) == "" {
+			return fmt.Errorf("column %d has no name", i+1)
+		}
+		if seen[column] {
+			return fmt.Errorf("duplicate column %q", column)
+		}
+		seen[column] = true
+	}
+	return nil
+}
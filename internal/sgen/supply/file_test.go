@@ -2,13 +2,15 @@ package supply
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 )
 
 func TestFileSync(t *testing.T) {
-	expect := []map[string]string{
+	expect := []map[string]any{
 		{"name": "bob"},
 		{"name": "alice"},
 	}
@@ -17,11 +19,15 @@ func TestFileSync(t *testing.T) {
 		"testdata/people.json",
 		"testdata/people.yaml",
 		"testdata/people.yml",
+		"testdata/people.jsonl",
 	}
 
 	for _, path := range paths {
 		t.Run(path, func(t *testing.T) {
-			s := File{path: path}
+			s, err := NewFileSupply(path, FileOptions{})
+			if err != nil {
+				t.Fatalf("NewFileSupply() error: %v", err)
+			}
 			data, err := s.Supply(context.Background())
 			if err != nil {
 				t.Fatalf("file sync error: %v", err)
@@ -34,3 +40,167 @@ func TestFileSync(t *testing.T) {
 		})
 	}
 }
+
+func TestFileSyncNested(t *testing.T) {
+	expect := []map[string]any{
+		{
+			"name":       "sgen",
+			"owner":      map[string]any{"login": "scnewma"},
+			"stars":      json.Number("12"),
+			"isArchived": false,
+			"topics":     []any{"cli", "go"},
+			"license":    nil,
+		},
+		{
+			"name":       "old",
+			"owner":      map[string]any{"login": "scnewma"},
+			"stars":      json.Number("0"),
+			"isArchived": true,
+			"topics":     []any{},
+			"license":    map[string]any{"key": "mit"},
+		},
+	}
+
+	paths := []string{
+		"testdata/repos.json",
+		"testdata/repos.yaml",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			s, err := NewFileSupply(path, FileOptions{})
+			if err != nil {
+				t.Fatalf("NewFileSupply() error: %v", err)
+			}
+			data, err := s.Supply(context.Background())
+			if err != nil {
+				t.Fatalf("file sync error: %v", err)
+			}
+
+			if diff := cmp.Diff(expect, data); diff != "" {
+				t.Errorf("File.Sync() data mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFileSyncCSV(t *testing.T) {
+	noHeader := false
+
+	tests := []struct {
+		name   string
+		path   string
+		opts   FileOptions
+		expect []map[string]any
+	}{
+		{
+			name: "csv with header",
+			path: "testdata/people.csv",
+			expect: []map[string]any{
+				{"name": "bob", "team": "platform, infra"},
+				{"name": "alice", "team": ""},
+			},
+		},
+		{
+			name: "csv with a byte order mark",
+			path: "testdata/people-bom.csv",
+			expect: []map[string]any{
+				{"name": "bob", "team": "platform"},
+				{"name": "alice", "team": "data"},
+			},
+		},
+		{
+			name: "tsv with header",
+			path: "testdata/people.tsv",
+			expect: []map[string]any{
+				{"name": "bob", "team": "platform"},
+				{"name": "alice", "team": `data "science"`},
+			},
+		},
+		{
+			name: "columns replace the header",
+			path: "testdata/people.csv",
+			opts: FileOptions{Columns: []string{"login", "group"}},
+			expect: []map[string]any{
+				{"login": "bob", "group": "platform, infra"},
+				{"login": "alice", "group": ""},
+			},
+		},
+		{
+			name: "select each row",
+			path: "testdata/people.csv",
+			opts: FileOptions{Select: "{login: .name}"},
+			expect: []map[string]any{
+				{"login": "bob"},
+				{"login": "alice"},
+			},
+		},
+		{
+			name: "no header with a custom delimiter and format",
+			path: "testdata/people.txt",
+			opts: FileOptions{
+				Format:    "csv",
+				Delimiter: ";",
+				HasHeader: &noHeader,
+				Columns:   []string{"name", "team"},
+			},
+			expect: []map[string]any{
+				{"name": "bob", "team": "platform"},
+				{"name": "alice", "team": "data"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewFileSupply(tt.path, tt.opts)
+			if err != nil {
+				t.Fatalf("NewFileSupply() error: %v", err)
+			}
+			data, err := s.Supply(context.Background())
+			if err != nil {
+				t.Fatalf("file sync error: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.expect, data); diff != "" {
+				t.Errorf("File.Sync() data mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewFileSupplyInvalidOptions(t *testing.T) {
+	noHeader := false
+
+	tests := []struct {
+		name string
+		path string
+		opts FileOptions
+	}{
+		{name: "unknown extension", path: "testdata/people.txt"},
+		{name: "unknown format", path: "testdata/people.csv", opts: FileOptions{Format: "xml"}},
+		{name: "no header without columns", path: "testdata/people.csv", opts: FileOptions{HasHeader: &noHeader}},
+		{name: "multi character delimiter", path: "testdata/people.csv", opts: FileOptions{Delimiter: "::"}},
+		{name: "duplicate columns", path: "testdata/people.csv", opts: FileOptions{Columns: []string{"a", "a"}}},
+		{name: "csv options for json", path: "testdata/people.json", opts: FileOptions{Delimiter: ";"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFileSupply(tt.path, tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestFileSyncMalformedJSONLines(t *testing.T) {
+	s, err := NewFileSupply("testdata/malformed.jsonl", FileOptions{})
+	if err != nil {
+		t.Fatalf("NewFileSupply() error: %v", err)
+	}
+	_, err = s.Supply(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error for line 2, got %v", err)
+	}
+}
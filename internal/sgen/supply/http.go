@@ -0,0 +1,361 @@
+package supply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scnewma/sgen/internal/encoding"
+	"github.com/scnewma/sgen/internal/fieldpath"
+	"github.com/scnewma/sgen/internal/selector"
+	"github.com/scnewma/sgen/internal/sgen"
+)
+
+const (
+	defaultMaxPages = 100
+	// defaultHTTPTimeout is how long a request can take when the source
+	// doesn't set a timeout, so that a hung API can't block syncing forever.
+	defaultHTTPTimeout = 30 * time.Second
+)
+
+type HTTPOptions struct {
+	URL    string
+	Method string
+	// Headers are sent with every request.
+	Headers map[string]string
+	// BearerTokenEnv is the name of an environment variable containing a
+	// token that is sent in the Authorization header. It is read when the
+	// source is supplied so that tokens never need to be in the config.
+	BearerTokenEnv string
+	Body           string
+	// Items is the path to the array of items in the response body, i.e.
+	// "data.repos". It is shorthand for the Select expression that iterates
+	// the array, .data.repos[]. The response body must be an array if
+	// neither is set.
+	Items string
+	// Select picks the records out of each response body, see
+	// selector.Selector. It can't be used with Items.
+	Select     string
+	Pagination *Pagination
+	// Timeout is how long each request can take, including reading the
+	// response body. It defaults to 30s.
+	Timeout time.Duration
+}
+
+type Pagination struct {
+	// Type is one of "link", "cursor" or "page".
+	//
+	// link: follows the URL in the rel="next" Link header.
+	// cursor: reads the next cursor from CursorPath in the response body and
+	// sends it as the CursorParam query parameter.
+	// page: increments the PageParam query parameter, starting at StartPage,
+	// until a page without any items is returned.
+	Type        string
+	CursorPath  string
+	CursorParam string
+	PageParam   string
+	StartPage   int
+	// MaxPages is a safeguard against servers that never stop paginating.
+	// Supplying fails if there are more pages after MaxPages, rather than
+	// silently returning some of the records.
+	MaxPages int
+}
+
+type HTTP struct {
+	opts   HTTPOptions
+	sel    *selector.Selector
+	client *http.Client
+}
+
+func NewHTTPSupply(opts HTTPOptions) (*HTTP, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("no url given")
+	}
+	if _, err := url.Parse(opts.URL); err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", opts.URL, err)
+	}
+	if opts.Method == "" {
+		opts.Method = http.MethodGet
+	}
+	opts.Method = strings.ToUpper(opts.Method)
+
+	if opts.Items != "" && opts.Select != "" {
+		return nil, fmt.Errorf("items and select are mutually exclusive")
+	}
+	expr := opts.Select
+	if opts.Items != "" {
+		expr = itemsSelect(opts.Items)
+	}
+	sel, err := parseSelect(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Pagination != nil {
+		// copied so that defaulting it doesn't change the caller's options
+		p := *opts.Pagination
+		opts.Pagination = &p
+		switch p.Type {
+		case "link":
+		case "cursor":
+			if p.CursorPath == "" || p.CursorParam == "" {
+				return nil, fmt.Errorf("cursor pagination requires cursor_path and cursor_param")
+			}
+		case "page":
+			if p.PageParam == "" {
+				return nil, fmt.Errorf("page pagination requires page_param")
+			}
+		default:
+			return nil, fmt.Errorf("invalid pagination type %q, valid types are [link,cursor,page]", p.Type)
+		}
+		if p.MaxPages <= 0 {
+			p.MaxPages = defaultMaxPages
+		}
+	}
+
+	switch {
+	case opts.Timeout < 0:
+		return nil, fmt.Errorf("timeout must not be negative")
+	case opts.Timeout == 0:
+		opts.Timeout = defaultHTTPTimeout
+	}
+
+	return &HTTP{opts: opts, sel: sel, client: &http.Client{Timeout: opts.Timeout}}, nil
+}
+
+func (s *HTTP) Supply(ctx context.Context) ([]map[string]any, error) {
+	var token string
+	if s.opts.BearerTokenEnv != "" {
+		token = os.Getenv(s.opts.BearerTokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("bearer token environment variable %q is not set", s.opts.BearerTokenEnv)
+		}
+	}
+
+	pagination := s.opts.Pagination
+	if pagination == nil {
+		pagination = &Pagination{MaxPages: 1}
+	}
+
+	var (
+		data    []map[string]any
+		nextURL = s.opts.URL
+		page    = pagination.StartPage
+	)
+	if pagination.Type == "page" {
+		var err error
+		nextURL, err = withQueryParam(nextURL, pagination.PageParam, strconv.Itoa(page))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < pagination.MaxPages && nextURL != ""; i++ {
+		body, header, err := s.do(ctx, nextURL, token)
+		if err != nil {
+			return nil, err
+		}
+
+		var decoded any
+		if err := encoding.DecodeJSON(body, &decoded); err != nil {
+			return nil, fmt.Errorf("decoding response from %q: %w", nextURL, err)
+		}
+		items, err := toRecords(decoded, s.sel)
+		if err != nil {
+			return nil, fmt.Errorf("response from %q: %w", nextURL, err)
+		}
+		data = append(data, items...)
+
+		switch pagination.Type {
+		case "link":
+			next := nextLink(header.Values("Link"))
+			if next == "" {
+				nextURL = ""
+				break
+			}
+			nextURL, err = resolveURL(nextURL, next)
+			if err != nil {
+				return nil, err
+			}
+		case "cursor":
+			cursor, _ := fieldpath.Lookup(decoded, pagination.CursorPath)
+			if cursor == nil || cursor == "" {
+				nextURL = ""
+				break
+			}
+			nextURL, err = withQueryParam(nextURL, pagination.CursorParam, fmt.Sprint(cursor))
+			if err != nil {
+				return nil, err
+			}
+		case "page":
+			if len(items) == 0 {
+				nextURL = ""
+				break
+			}
+			page++
+			nextURL, err = withQueryParam(nextURL, pagination.PageParam, strconv.Itoa(page))
+			if err != nil {
+				return nil, err
+			}
+		default:
+			nextURL = ""
+		}
+	}
+	if nextURL != "" {
+		return nil, fmt.Errorf("stopped after max_pages (%d) with more pages remaining, increase max_pages to get every record", pagination.MaxPages)
+	}
+	return data, nil
+}
+
+func (s *HTTP) ShouldCache() bool {
+	return true
+}
+
+func (s *HTTP) do(ctx context.Context, u, token string) ([]byte, http.Header, error) {
+	var body io.Reader
+	if s.opts.Body != "" {
+		body = strings.NewReader(s.opts.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, s.opts.Method, u, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range s.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, s.timeoutError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response from %q: %w", u, s.timeoutError(ctx, err))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("%s %q: %s: %s", s.opts.Method, u, resp.Status, truncate(string(buf), 512))
+	}
+	return buf, resp.Header, nil
+}
+
+// timeoutError returns a TimeoutError if err is from the request taking longer
+// than the timeout, rather than ctx being cancelled, otherwise err.
+func (s *HTTP) timeoutError(ctx context.Context, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() && ctx.Err() == nil {
+		return &sgen.TimeoutError{Timeout: s.opts.Timeout}
+	}
+	return err
+}
+
+// itemsSelect returns the select expression that iterates the array at the
+// dotted path items.
+func itemsSelect(items string) string {
+	var b strings.Builder
+	b.WriteString(".")
+	for _, seg := range strings.Split(strings.TrimPrefix(items, "."), ".") {
+		if _, err := strconv.Atoi(seg); err == nil {
+			fmt.Fprintf(&b, "[%s]", seg)
+		} else {
+			fmt.Fprintf(&b, "[%s]", strconv.Quote(seg))
+		}
+	}
+	b.WriteString("[]")
+	return b.String()
+}
+
+// nextLink finds the rel="next" URL in RFC 8288 Link headers, i.e.
+// `<https://api.github.com/repos?page=2>; rel="next"`.
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for _, link := range splitLinks(header) {
+			link = strings.TrimSpace(link)
+			end := strings.IndexByte(link, '>')
+			if !strings.HasPrefix(link, "<") || end < 0 {
+				continue
+			}
+			target := link[1:end]
+			for _, param := range strings.Split(link[end+1:], ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.TrimSpace(k) != "rel" {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(v, `"`)) {
+					if rel == "next" {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitLinks splits a Link header into its links at the commas between them.
+// Commas inside a link's <URI> or a quoted parameter, which RFC 8288 allows,
+// don't separate links.
+func splitLinks(header string) []string {
+	var links []string
+	var inURI, inQuotes bool
+	start := 0
+	for i, c := range header {
+		switch {
+		case inURI:
+			inURI = c != '>'
+		case inQuotes:
+			inQuotes = c != '"'
+		case c == '<':
+			inURI = true
+		case c == '"':
+			inQuotes = true
+		case c == ',':
+			links = append(links, header[start:i])
+			start = i + 1
+		}
+	}
+	return append(links, header[start:])
+}
+
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %q: %w", base, err)
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %q: %w", ref, err)
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+func withQueryParam(u, key, value string) (string, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %q: %w", u, err)
+	}
+	q := parsed.Query()
+	q.Set(key, value)
+	parsed.RawQuery = q.Encode()
+	return parsed.String(), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,236 @@
+package supply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/scnewma/sgen/internal/sgen"
+)
+
+func TestHTTPSupply(t *testing.T) {
+	expect := []map[string]any{
+		{"name": "bob"},
+		{"name": "alice"},
+	}
+
+	pages := []string{
+		`[{"name":"bob"}]`,
+		`[{"name":"alice"}]`,
+	}
+
+	tests := []struct {
+		name    string
+		opts    func(url string) HTTPOptions
+		handler http.HandlerFunc
+	}{
+		{
+			name: "single request",
+			opts: func(url string) HTTPOptions {
+				return HTTPOptions{URL: url}
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `[{"name":"bob"},{"name":"alice"}]`)
+			},
+		},
+		{
+			name: "select",
+			opts: func(url string) HTTPOptions {
+				return HTTPOptions{URL: url, Select: ".results[] | {name: .person.name}"}
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"results":[{"person":{"name":"bob"}},{"person":{"name":"alice"}}]}`)
+			},
+		},
+		{
+			name: "items path and headers",
+			opts: func(url string) HTTPOptions {
+				return HTTPOptions{
+					URL:            url,
+					Method:         "post",
+					Headers:        map[string]string{"X-Org": "sgen"},
+					BearerTokenEnv: "SGEN_TEST_TOKEN",
+					Items:          "data.people",
+				}
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					http.Error(w, "bad method "+r.Method, http.StatusMethodNotAllowed)
+					return
+				}
+				if r.Header.Get("X-Org") != "sgen" || r.Header.Get("Authorization") != "Bearer secret" {
+					http.Error(w, "missing headers", http.StatusUnauthorized)
+					return
+				}
+				fmt.Fprint(w, `{"data":{"people":[{"name":"bob"},{"name":"alice"}]}}`)
+			},
+		},
+		{
+			name: "link pagination",
+			opts: func(url string) HTTPOptions {
+				return HTTPOptions{URL: url, Pagination: &Pagination{Type: "link"}}
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("page") == "" {
+					w.Header().Set("Link", `</?page=2>; rel="next", </?page=2>; rel="last"`)
+					fmt.Fprint(w, pages[0])
+					return
+				}
+				fmt.Fprint(w, pages[1])
+			},
+		},
+		{
+			name: "cursor pagination",
+			opts: func(url string) HTTPOptions {
+				return HTTPOptions{
+					URL:   url,
+					Items: "items",
+					Pagination: &Pagination{
+						Type:        "cursor",
+						CursorPath:  "meta.next",
+						CursorParam: "after",
+					},
+				}
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Query().Get("after") {
+				case "":
+					fmt.Fprintf(w, `{"items":%s,"meta":{"next":"abc"}}`, pages[0])
+				case "abc":
+					fmt.Fprintf(w, `{"items":%s,"meta":{"next":null}}`, pages[1])
+				default:
+					http.Error(w, "bad cursor", http.StatusBadRequest)
+				}
+			},
+		},
+		{
+			name: "page pagination",
+			opts: func(url string) HTTPOptions {
+				return HTTPOptions{
+					URL:        url,
+					Pagination: &Pagination{Type: "page", PageParam: "p", StartPage: 1},
+				}
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Query().Get("p") {
+				case "1":
+					fmt.Fprint(w, pages[0])
+				case "2":
+					fmt.Fprint(w, pages[1])
+				default:
+					fmt.Fprint(w, `[]`)
+				}
+			},
+		},
+	}
+
+	t.Setenv("SGEN_TEST_TOKEN", "secret")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			s, err := NewHTTPSupply(tt.opts(srv.URL))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, err := s.Supply(context.Background())
+			if err != nil {
+				t.Fatalf("http supply error: %v", err)
+			}
+
+			if diff := cmp.Diff(expect, data); diff != "" {
+				t.Errorf("HTTP.Supply() data mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		header string
+		expect string
+	}{
+		{`<https://api.example.com/items?page=2>; rel="next"`, "https://api.example.com/items?page=2"},
+		{`<https://api.example.com/items?page=1>; rel="prev", <https://api.example.com/items?page=3>; rel="next"`, "https://api.example.com/items?page=3"},
+		// commas and semicolons are allowed in the URI and quoted parameters
+		{`<https://api.example.com/items?ids=1,2;page=2>; title="a, b"; rel="next"`, "https://api.example.com/items?ids=1,2;page=2"},
+		{`<https://api.example.com/items?ids=1,2>; rel="last", <https://api.example.com/items?ids=3,4>; rel="next last"`, "https://api.example.com/items?ids=3,4"},
+		{`<https://api.example.com/items?page=9>; rel="last"`, ""},
+	}
+	for _, tt := range tests {
+		if got := nextLink([]string{tt.header}); got != tt.expect {
+			t.Errorf("nextLink(%q) = %q, want %q", tt.header, got, tt.expect)
+		}
+	}
+}
+
+func TestHTTPSupplyErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s, err := NewHTTPSupply(HTTPOptions{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Supply(context.Background()); err == nil {
+		t.Fatal("expected error for non-2xx response")
+	}
+}
+
+func TestHTTPSupplyMaxPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"bob"}]`)
+	}))
+	defer srv.Close()
+
+	s, err := NewHTTPSupply(HTTPOptions{
+		URL:        srv.URL,
+		Pagination: &Pagination{Type: "page", PageParam: "page", MaxPages: 2},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Supply(context.Background()); err == nil || !strings.Contains(err.Error(), "max_pages (2)") {
+		t.Errorf("expected an error for the remaining pages, got %v", err)
+	}
+}
+
+func TestNewHTTPSupplyCopiesPagination(t *testing.T) {
+	p := &Pagination{Type: "link"}
+	if _, err := NewHTTPSupply(HTTPOptions{URL: "https://example.com", Pagination: p}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.MaxPages != 0 {
+		t.Errorf("NewHTTPSupply() set the caller's MaxPages to %d", p.MaxPages)
+	}
+}
+
+func TestHTTPSupplyTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	s, err := NewHTTPSupply(HTTPOptions{URL: srv.URL, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = s.Supply(context.Background())
+	var timeout *sgen.TimeoutError
+	if !errors.As(err, &timeout) || timeout.Timeout != 100*time.Millisecond {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+}
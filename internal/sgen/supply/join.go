@@ -0,0 +1,142 @@
+package supply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/scnewma/sgen/internal/encoding"
+	"github.com/scnewma/sgen/internal/fieldpath"
+	"github.com/scnewma/sgen/internal/records"
+)
+
+// Loader loads the records of another source.
+type Loader interface {
+	Load(context.Context) ([]map[string]any, error)
+}
+
+type JoinOptions struct {
+	Left  Loader
+	Right Loader
+	// LeftKey and RightKey are the dotted paths to the fields that must be
+	// equal for a left and right record to be joined.
+	LeftKey  string
+	RightKey string
+	// Type is either "inner" (the default), which only keeps left records
+	// with a matching right record, or "left", which keeps every left record.
+	Type string
+	// RightPrefix is prepended to the names of the right record's fields, so
+	// that they don't collide with the left record's, i.e. "owner_".
+	RightPrefix string
+}
+
+// Join merges the records of two sources by key. A left record is merged
+// with every right record it matches, so it may appear more than once.
+type Join struct {
+	opts JoinOptions
+}
+
+func NewJoinSupply(opts JoinOptions) (*Join, error) {
+	if opts.LeftKey == "" || opts.RightKey == "" {
+		return nil, fmt.Errorf("join keys are required for both sides of the join")
+	}
+	switch opts.Type {
+	case "":
+		opts.Type = "inner"
+	case "inner", "left":
+	default:
+		return nil, fmt.Errorf("invalid join type %q, valid types are [inner,left]", opts.Type)
+	}
+	return &Join{opts: opts}, nil
+}
+
+func (s *Join) Supply(ctx context.Context) ([]map[string]any, error) {
+	left, err := s.opts.Left.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading left source: %w", err)
+	}
+	right, err := s.opts.Right.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading right source: %w", err)
+	}
+
+	index := make(map[string][]map[string]any)
+	for _, record := range right {
+		if key, ok := joinKey(record, s.opts.RightKey); ok {
+			index[key] = append(index[key], record)
+		}
+	}
+
+	data := []map[string]any{}
+	for _, record := range left {
+		var matches []map[string]any
+		if key, ok := joinKey(record, s.opts.LeftKey); ok {
+			matches = index[key]
+		}
+		if len(matches) == 0 {
+			if s.opts.Type == "left" {
+				data = append(data, record)
+			}
+			continue
+		}
+		for _, match := range matches {
+			data = append(data, mergeRecords(record, match, s.opts.RightPrefix))
+		}
+	}
+	return data, nil
+}
+
+func (s *Join) ShouldCache() bool {
+	return true
+}
+
+// joinKey returns a comparable key for the value at path. Scalars are keyed by
+// their text, so that the "1" of a csv file matches the 1 of JSON data, and
+// objects and arrays by their JSON. Records where the value is missing or
+// null never match anything.
+func joinKey(record map[string]any, path string) (string, bool) {
+	v, found := fieldpath.Lookup(record, path)
+	if !found || v == nil {
+		return "", false
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number, float64:
+		return numberKey(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	key, err := encoding.EncodeJSONString(v)
+	return key, err == nil
+}
+
+// numberKey formats a number the same way however it was written, i.e. 1,
+// 1.0 and 1e0 are all "1".
+func numberKey(v any) string {
+	switch n := records.NativeNumbers(v).(type) {
+	case int64:
+		return strconv.FormatInt(n, 10)
+	case float64:
+		if n == math.Trunc(n) && math.Abs(n) < math.MaxInt64 {
+			return strconv.FormatInt(int64(n), 10)
+		}
+		return strconv.FormatFloat(n, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// mergeRecords adds the fields of right, with prefix prepended to their
+// names, to a copy of left. Fields that are in both keep the left value.
+func mergeRecords(left, right map[string]any, prefix string) map[string]any {
+	merged := make(map[string]any, len(left)+len(right))
+	for k, v := range right {
+		merged[prefix+k] = v
+	}
+	for k, v := range left {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,129 @@
+package supply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type staticLoader []map[string]any
+
+func (l staticLoader) Load(context.Context) ([]map[string]any, error) {
+	return l, nil
+}
+
+func TestJoinSupply(t *testing.T) {
+	repos := staticLoader{
+		{"name": "sgen", "owner": map[string]any{"login": "scnewma"}},
+		{"name": "orphan", "owner": map[string]any{"login": "nobody"}},
+		{"name": "ownerless"},
+	}
+	users := staticLoader{
+		{"login": "scnewma", "name": "Shaun", "team": "tools"},
+	}
+
+	tests := []struct {
+		name        string
+		joinType    string
+		rightPrefix string
+		expect      []map[string]any
+	}{
+		{
+			name: "inner",
+			expect: []map[string]any{
+				{"name": "sgen", "owner": map[string]any{"login": "scnewma"}, "login": "scnewma", "team": "tools"},
+			},
+		},
+		{
+			name:     "left",
+			joinType: "left",
+			expect: []map[string]any{
+				{"name": "sgen", "owner": map[string]any{"login": "scnewma"}, "login": "scnewma", "team": "tools"},
+				{"name": "orphan", "owner": map[string]any{"login": "nobody"}},
+				{"name": "ownerless"},
+			},
+		},
+		{
+			// the right record's name no longer collides with the left's
+			name:        "right prefix",
+			rightPrefix: "owner_",
+			expect: []map[string]any{
+				{"name": "sgen", "owner": map[string]any{"login": "scnewma"}, "owner_login": "scnewma", "owner_name": "Shaun", "owner_team": "tools"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewJoinSupply(JoinOptions{
+				Left:        repos,
+				Right:       users,
+				LeftKey:     "owner.login",
+				RightKey:    "login",
+				Type:        tt.joinType,
+				RightPrefix: tt.rightPrefix,
+			})
+			if err != nil {
+				t.Fatalf("NewJoinSupply() error: %v", err)
+			}
+			data, err := s.Supply(context.Background())
+			if err != nil {
+				t.Fatalf("Join.Supply() error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expect, data); diff != "" {
+				t.Errorf("Join.Supply() data mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJoinSupplyMixedKeyTypes(t *testing.T) {
+	// ids read from a csv file are strings, while JSON data has numbers
+	issues := staticLoader{
+		{"title": "crash", "assignee": "1"},
+		{"title": "typo", "assignee": "2.0"},
+		{"title": "docs", "assignee": json.Number("3")},
+		{"title": "flaky", "assignee": "true"},
+	}
+	users := staticLoader{
+		{"id": json.Number("1"), "name": "alice"},
+		{"id": json.Number("2"), "name": "bob"},
+		{"id": float64(3), "name": "carol"},
+		{"id": true, "name": "bot"},
+	}
+	s, err := NewJoinSupply(JoinOptions{
+		Left:        issues,
+		Right:       users,
+		LeftKey:     "assignee",
+		RightKey:    "id",
+		RightPrefix: "user_",
+	})
+	if err != nil {
+		t.Fatalf("NewJoinSupply() error: %v", err)
+	}
+	data, err := s.Supply(context.Background())
+	if err != nil {
+		t.Fatalf("Join.Supply() error: %v", err)
+	}
+	var got []string
+	for _, record := range data {
+		got = append(got, fmt.Sprintf("%s: %s", record["title"], record["user_name"]))
+	}
+	// "2.0" is a string, so it only matches the string "2.0"
+	expect := []string{"crash: alice", "docs: carol", "flaky: bot"}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Errorf("Join.Supply() data mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewJoinSupplyInvalid(t *testing.T) {
+	if _, err := NewJoinSupply(JoinOptions{LeftKey: "id"}); err == nil {
+		t.Error("expected an error for a missing right key")
+	}
+	if _, err := NewJoinSupply(JoinOptions{LeftKey: "id", RightKey: "id", Type: "outer"}); err == nil {
+		t.Error("expected an error for an invalid join type")
+	}
+}
@@ -0,0 +1,49 @@
+package supply
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/scnewma/sgen/internal/encoding"
+	"github.com/scnewma/sgen/internal/records"
+	"github.com/scnewma/sgen/internal/selector"
+)
+
+// decodeJSONLines decodes JSON Lines (also known as NDJSON), one object per
+// line, as they are read from r. Blank lines are skipped. If sel is set it is
+// run on each line to select the records from it.
+func decodeJSONLines(r io.Reader, sel *selector.Selector) ([]map[string]any, error) {
+	br := bufio.NewReader(r)
+	data := []map[string]any{}
+	for line := 1; ; line++ {
+		buf, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		if trimmed := bytes.TrimSpace(buf); len(trimmed) > 0 {
+			var v any
+			if err := encoding.DecodeJSON(trimmed, &v); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if sel != nil {
+				selected, err := sel.Records(v)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				data = append(data, selected...)
+			} else if record, ok := v.(map[string]any); ok {
+				data = append(data, record)
+			} else {
+				return nil, fmt.Errorf("line %d: expected an object, got %s", line, records.TypeName(v))
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return data, nil
+		}
+	}
+}
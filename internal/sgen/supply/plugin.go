@@ -0,0 +1,227 @@
+package supply
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/scnewma/sgen/internal/encoding"
+	"github.com/scnewma/sgen/internal/selector"
+)
+
+// PluginPrefix is the prefix of the names of plugin executables. A plugin
+// named sgen-supplier-jira supplies sources of type "jira".
+const PluginPrefix = "sgen-supplier-"
+
+// PluginProtocol is the version of the plugin protocol, sent to plugins with
+// every request.
+//
+// Plugins are run with a single argument:
+//
+//   - schema: the plugin writes a PluginSchema describing the attributes of
+//     its sources to stdout.
+//   - supply: the plugin reads a PluginRequest from stdin and writes
+//     {"records": [...]} to stdout.
+//
+// Either can instead write {"error": {"message": "..."}} to report a failure.
+const PluginProtocol = 1
+
+// PluginSchema describes the sources a plugin supplies.
+type PluginSchema struct {
+	// Summary describes the type in a sentence.
+	Summary string `json:"summary"`
+	// Cache is whether the plugin's records are cached until the source is
+	// synced, like a command source, rather than supplied every time. It
+	// defaults to true.
+	Cache *bool `json:"cache,omitempty"`
+	// Attributes are the attributes of the plugin's source blocks, in
+	// addition to the properties common to all sources.
+	Attributes []PluginAttribute `json:"attributes"`
+}
+
+// PluginAttribute is an attribute of a plugin's source blocks.
+type PluginAttribute struct {
+	Name string `json:"name"`
+	// Type is an HCL type constraint, i.e. "string", "number", "bool" or
+	// "list(string)". Attributes without a type can be any value.
+	Type        string `json:"type,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// PluginRequest is sent to a plugin on stdin to supply a source's records.
+type PluginRequest struct {
+	Protocol int    `json:"protocol"`
+	Type     string `json:"type"`
+	Source   string `json:"source"`
+	// Attributes are the source block's attributes declared by the plugin's
+	// schema. Attributes that aren't set are left out.
+	Attributes map[string]json.RawMessage `json:"attributes"`
+}
+
+// PluginError is a failure reported by a plugin.
+type PluginError struct {
+	Message string `json:"message"`
+}
+
+type pluginResponse struct {
+	Error   *PluginError `json:"error"`
+	Records any          `json:"records"`
+}
+
+// FindPlugin returns the path of the plugin for sources of type typ on the
+// PATH.
+func FindPlugin(typ string) (string, error) {
+	return exec.LookPath(PluginPrefix + typ)
+}
+
+// FindPlugins returns the paths of the plugins on the PATH by the source type
+// they supply.
+func FindPlugins() map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			typ, found := strings.CutPrefix(entry.Name(), PluginPrefix)
+			if !found || typ == "" {
+				continue
+			}
+			if _, seen := plugins[typ]; seen {
+				continue
+			}
+			// the plugin that would be run for the type, which may be in
+			// an earlier directory if this one isn't executable
+			if path, err := FindPlugin(typ); err == nil {
+				plugins[typ] = path
+			}
+		}
+	}
+	return plugins
+}
+
+// ReadPluginSchema runs the plugin at path to get its schema.
+func ReadPluginSchema(ctx context.Context, path string) (*PluginSchema, error) {
+	out, err := runPlugin(ctx, path, "schema", nil)
+	if err != nil {
+		return nil, err
+	}
+	var schema PluginSchema
+	if err := json.Unmarshal(out, &schema); err != nil {
+		return nil, fmt.Errorf("decoding schema of plugin %s: %w", path, err)
+	}
+	for _, attr := range schema.Attributes {
+		if attr.Name == "" {
+			return nil, fmt.Errorf("plugin %s declares an attribute without a name", path)
+		}
+	}
+	return &schema, nil
+}
+
+type PluginOptions struct {
+	// Path is the plugin executable.
+	Path string
+	// Type and Source are the type and name of the source, which are passed
+	// on to the plugin.
+	Type   string
+	Source string
+	// Attributes are the source block's attributes as JSON, by name.
+	Attributes map[string]json.RawMessage
+	// Cache is whether the records are cached, see PluginSchema.Cache.
+	Cache bool
+	// Select picks the records out of the plugin's records, see
+	// selector.Selector.
+	Select string
+}
+
+// Plugin supplies records by running an external plugin executable, see
+// PluginProtocol.
+type Plugin struct {
+	opts PluginOptions
+	sel  *selector.Selector
+}
+
+func NewPluginSupply(opts PluginOptions) (*Plugin, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("no plugin given")
+	}
+	sel, err := parseSelect(opts.Select)
+	if err != nil {
+		return nil, err
+	}
+	return &Plugin{opts: opts, sel: sel}, nil
+}
+
+func (s *Plugin) Supply(ctx context.Context) ([]map[string]any, error) {
+	attrs := s.opts.Attributes
+	if attrs == nil {
+		attrs = map[string]json.RawMessage{}
+	}
+	req := PluginRequest{
+		Protocol:   PluginProtocol,
+		Type:       s.opts.Type,
+		Source:     s.opts.Source,
+		Attributes: attrs,
+	}
+	out, err := runPlugin(ctx, s.opts.Path, "supply", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp pluginResponse
+	if err := encoding.DecodeJSON(out, &resp); err != nil {
+		return nil, fmt.Errorf("decoding output of plugin %s: %w", s.opts.Path, err)
+	}
+	if resp.Records == nil && s.sel == nil {
+		return nil, fmt.Errorf("plugin %s did not return any records", s.opts.Path)
+	}
+	return toRecords(resp.Records, s.sel)
+}
+
+func (s *Plugin) ShouldCache() bool {
+	return s.opts.Cache
+}
+
+// runPlugin runs the plugin at path with arg, writing req to its stdin as
+// JSON, and returns its output. Errors the plugin reports are returned as
+// errors.
+func runPlugin(ctx context.Context, path, arg string, req any) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, path, arg)
+	if req != nil {
+		in, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdin = bytes.NewReader(in)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	// a structured error is preferred to the exit status, since it is what
+	// the plugin wants to be shown
+	var resp struct {
+		Error *PluginError `json:"error"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err == nil && resp.Error != nil {
+		return nil, fmt.Errorf("plugin %s: %s", path, resp.Error.Message)
+	}
+
+	if runErr != nil {
+		var exitError *exec.ExitError
+		if errors.As(runErr, &exitError) {
+			return nil, fmt.Errorf("running plugin %s %s: stderr: %s", path, arg, strings.TrimSpace(stderr.String()))
+		}
+		return nil, runErr
+	}
+	return stdout.Bytes(), nil
+}
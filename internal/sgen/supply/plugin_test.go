@@ -0,0 +1,73 @@
+package supply
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPluginSupply(t *testing.T) {
+	s, err := NewPluginSupply(PluginOptions{
+		Path:   "testdata/plugins/sgen-supplier-echo",
+		Type:   "echo",
+		Source: "tickets",
+		Attributes: map[string]json.RawMessage{
+			"project": json.RawMessage(`"SGEN"`),
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPluginSupply() error: %v", err)
+	}
+	data, err := s.Supply(context.Background())
+	if err != nil {
+		t.Fatalf("Plugin.Supply() error: %v", err)
+	}
+
+	expect := []map[string]any{{
+		"protocol":   json.Number(strconv.Itoa(PluginProtocol)),
+		"type":       "echo",
+		"source":     "tickets",
+		"attributes": map[string]any{"project": "SGEN"},
+	}}
+	if diff := cmp.Diff(expect, data); diff != "" {
+		t.Errorf("Plugin.Supply() data mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadPluginSchema(t *testing.T) {
+	schema, err := ReadPluginSchema(context.Background(), "testdata/plugins/sgen-supplier-echo")
+	if err != nil {
+		t.Fatalf("ReadPluginSchema() error: %v", err)
+	}
+	expect := &PluginSchema{
+		Summary: "Supplies the request it was sent.",
+		Attributes: []PluginAttribute{
+			{Name: "project", Type: "string", Required: true},
+			{Name: "limit", Type: "number"},
+			{Name: "labels", Type: "list(string)"},
+		},
+	}
+	if diff := cmp.Diff(expect, schema); diff != "" {
+		t.Errorf("ReadPluginSchema() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPluginErrors(t *testing.T) {
+	const broken = "testdata/plugins/sgen-supplier-broken"
+
+	s, err := NewPluginSupply(PluginOptions{Path: broken})
+	if err != nil {
+		t.Fatalf("NewPluginSupply() error: %v", err)
+	}
+	if _, err := s.Supply(context.Background()); err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("expected the plugin's error, got %v", err)
+	}
+
+	if _, err := ReadPluginSchema(context.Background(), broken); err == nil || !strings.Contains(err.Error(), "no schema") {
+		t.Errorf("expected the plugin's stderr, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package supply
+
+import (
+	"fmt"
+
+	"github.com/scnewma/sgen/internal/records"
+	"github.com/scnewma/sgen/internal/selector"
+)
+
+// toRecords converts decoded output into records. Without a selector the
+// output must already be an array of objects. A null document is no records,
+// since that's what tools like gh --jq output when there's nothing to list.
+func toRecords(v any, sel *selector.Selector) ([]map[string]any, error) {
+	if v == nil {
+		return []map[string]any{}, nil
+	}
+	if sel != nil {
+		return sel.Records(v)
+	}
+
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array of objects, got %s, use select to pick the records out of it", records.TypeName(v))
+	}
+	data := make([]map[string]any, 0, len(arr))
+	for i, item := range arr {
+		record, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object at index %d, got %s", i, records.TypeName(item))
+		}
+		data = append(data, record)
+	}
+	return data, nil
+}
+
+// parseSelect compiles expr, which may be empty for no selector.
+func parseSelect(expr string) (*selector.Selector, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return selector.Parse(expr)
+}
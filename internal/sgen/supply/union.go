@@ -0,0 +1,60 @@
+package supply
+
+import (
+	"context"
+	"fmt"
+)
+
+// UnionInput is one of the sources concatenated by a union.
+type UnionInput struct {
+	Name   string
+	Source Loader
+}
+
+type UnionOptions struct {
+	Sources []UnionInput
+	// Field is the name of the field set to the name of the source each
+	// record came from. Defaults to "_source".
+	Field string
+}
+
+// Union concatenates the records of several sources, in order, marking each
+// record with the name of its source.
+type Union struct {
+	opts UnionOptions
+}
+
+func NewUnionSupply(opts UnionOptions) (*Union, error) {
+	if len(opts.Sources) == 0 {
+		return nil, fmt.Errorf("at least one source is required")
+	}
+	if opts.Field == "" {
+		opts.Field = "_source"
+	}
+	return &Union{opts: opts}, nil
+}
+
+func (s *Union) Supply(ctx context.Context) ([]map[string]any, error) {
+	data := []map[string]any{}
+	for _, input := range s.opts.Sources {
+		records, err := input.Source.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("loading source %q: %w", input.Name, err)
+		}
+		for _, record := range records {
+			// records are copied since they may be shared with the input,
+			// i.e. if it isn't cached
+			marked := make(map[string]any, len(record)+1)
+			for k, v := range record {
+				marked[k] = v
+			}
+			marked[s.opts.Field] = input.Name
+			data = append(data, marked)
+		}
+	}
+	return data, nil
+}
+
+func (s *Union) ShouldCache() bool {
+	return true
+}
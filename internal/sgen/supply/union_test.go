@@ -0,0 +1,45 @@
+package supply
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnionSupply(t *testing.T) {
+	work := staticLoader{{"name": "api"}, {"name": "web"}}
+	personal := staticLoader{{"name": "sgen", "_source": "upstream"}}
+
+	s, err := NewUnionSupply(UnionOptions{
+		Sources: []UnionInput{
+			{Name: "work", Source: work},
+			{Name: "personal", Source: personal},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewUnionSupply() error: %v", err)
+	}
+	data, err := s.Supply(context.Background())
+	if err != nil {
+		t.Fatalf("Union.Supply() error: %v", err)
+	}
+
+	expect := []map[string]any{
+		{"name": "api", "_source": "work"},
+		{"name": "web", "_source": "work"},
+		{"name": "sgen", "_source": "personal"},
+	}
+	if diff := cmp.Diff(expect, data); diff != "" {
+		t.Errorf("Union.Supply() data mismatch (-want +got):\n%s", diff)
+	}
+	if _, found := work[0]["_source"]; found {
+		t.Error("Union.Supply() modified the input records")
+	}
+}
+
+func TestNewUnionSupplyInvalid(t *testing.T) {
+	if _, err := NewUnionSupply(UnionOptions{}); err == nil {
+		t.Error("expected an error for a union without sources")
+	}
+}
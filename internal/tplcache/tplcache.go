@@ -3,10 +3,10 @@ package tplcache
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"io"
 	"os"
 	"path/filepath"
 
+	"github.com/scnewma/sgen/internal/fsutil"
 	"github.com/scnewma/sgen/internal/sgen"
 )
 
@@ -35,16 +35,19 @@ func (c *Cache) Set(src, tpl string, data []byte) error {
 	if err := os.MkdirAll(d, 0755); err != nil {
 		return err
 	}
-	return os.WriteFile(p, data, 0755)
+	return fsutil.WriteFileAtomic(p, data, 0755)
 }
 
-func (c *Cache) Open(src, tpl string) (io.WriteCloser, error) {
+// Open returns a file to write the output of tpl to. Nothing is cached until
+// the file is committed, so output that fails part way through is never
+// served from the cache.
+func (c *Cache) Open(src, tpl string) (*fsutil.AtomicFile, error) {
 	d := filepath.Join(c.srcDir(src), c.hash(tpl))
 	p := filepath.Join(d, "out")
 	if err := os.MkdirAll(d, 0755); err != nil {
 		return nil, err
 	}
-	return os.Create(p)
+	return fsutil.CreateAtomic(p, 0755)
 }
 
 func (c *Cache) Get(src, tpl string) ([]byte, error) {
@@ -0,0 +1,256 @@
+package sgen
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/scnewma/sgen/internal/client"
+	"github.com/scnewma/sgen/internal/hclconfig"
+	core "github.com/scnewma/sgen/internal/sgen"
+)
+
+// Client loads, syncs and generates output from sources, both those in an
+// sgen configuration and those registered with WithSource. It is safe for
+// concurrent use.
+type Client struct {
+	c        *client.Client
+	cacheDir string
+}
+
+type clientOptions struct {
+	config      *Config
+	configDir   string
+	variables   map[string]string
+	cacheDir    string
+	sources     []*Source
+	renderers   map[string]Renderer
+	memory      bool
+	background  func(names []string) error
+	parallelism int
+}
+
+// Option configures a Client.
+type Option func(*clientOptions)
+
+// Config is a parsed sgen configuration, see LoadConfig.
+type Config struct {
+	config *hclconfig.Config
+}
+
+// LoadConfig parses the configuration in the *.hcl files in dir, with the
+// variables set by WithVariables. The schemas of plugins are cached in the
+// directory set by WithCacheDir. Other options are ignored.
+func LoadConfig(dir string, opts ...Option) (*Config, error) {
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return loadConfig(dir, options)
+}
+
+func loadConfig(dir string, options clientOptions) (*Config, error) {
+	config, diags := hclconfig.ParseDir(dir,
+		hclconfig.WithVariables(options.variables),
+		hclconfig.WithCacheDir(options.cacheDir),
+	)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("loading configuration: %w", diags)
+	}
+	return &Config{config: config}, nil
+}
+
+// SourceNames returns the names of the configured sources, sorted.
+func (c *Config) SourceNames() []string {
+	names := make([]string, 0, len(c.config.Sources))
+	for name := range c.config.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithConfig uses an already parsed configuration.
+func WithConfig(config *Config) Option {
+	return func(opts *clientOptions) {
+		opts.config = config
+	}
+}
+
+// WithConfigDir loads the configuration from the *.hcl files in dir, see
+// DefaultConfigDir.
+func WithConfigDir(dir string) Option {
+	return func(opts *clientOptions) {
+		opts.configDir = dir
+	}
+}
+
+// WithVariables sets the values of the configuration's variables, like
+// sgen's --var flag. It only applies to WithConfigDir.
+func WithVariables(vars map[string]string) Option {
+	return func(opts *clientOptions) {
+		opts.variables = vars
+	}
+}
+
+// WithCacheDir caches synced data and rendered output in dir rather than in
+// DefaultCacheDir. The sgen command only shares sources synced by a client
+// that uses its cache directory.
+func WithCacheDir(dir string) Option {
+	return func(opts *clientOptions) {
+		opts.cacheDir = dir
+	}
+}
+
+// WithSource registers a source that isn't in the configuration. Its name
+// must not clash with a configured source. Sources without a "default"
+// renderer render their records as JSON.
+func WithSource(src *Source) Option {
+	return func(opts *clientOptions) {
+		opts.sources = append(opts.sources, src)
+	}
+}
+
+// WithRenderer registers a named renderer for every source, which can be
+// selected with WithNamedRenderer. Templates that a source defines with the
+// same name take precedence.
+func WithRenderer(name string, r Renderer) Option {
+	return func(opts *clientOptions) {
+		if opts.renderers == nil {
+			opts.renderers = make(map[string]Renderer)
+		}
+		opts.renderers[name] = r
+	}
+}
+
+// WithMemoryCache keeps the records of cached sources in memory between calls
+// to Generate, until the source is synced again. It's intended for long
+// running processes, like sgen serve.
+func WithMemoryCache() Option {
+	return func(opts *clientOptions) {
+		opts.memory = true
+	}
+}
+
+// WithBackgroundRefresh has Refresh call fn with the names of expired sources
+// rather than syncing them before returning, so stale data is served while
+// fn updates them. fn must not wait for the sources to sync.
+func WithBackgroundRefresh(fn func(names []string) error) Option {
+	return func(opts *clientOptions) {
+		opts.background = fn
+	}
+}
+
+// WithDefaultParallelism sets the maximum number of sources that are synced
+// at the same time when Sync isn't given WithParallelism, and by Refresh.
+func WithDefaultParallelism(n int) Option {
+	return func(opts *clientOptions) {
+		opts.parallelism = n
+	}
+}
+
+// New creates a Client. Without WithConfig or WithConfigDir the client only
+// has the sources registered with WithSource.
+func New(opts ...Option) (*Client, error) {
+	options := clientOptions{parallelism: DefaultParallelism}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	cacheDir := options.cacheDir
+	if cacheDir == "" {
+		var err error
+		if cacheDir, err = DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	options.cacheDir = cacheDir
+
+	config := options.config
+	if config == nil && options.configDir != "" {
+		var err error
+		if config, err = loadConfig(options.configDir, options); err != nil {
+			return nil, err
+		}
+	}
+
+	clientOpts := []client.Option{
+		client.WithCacheDir(cacheDir),
+		client.WithDefaultParallelism(options.parallelism),
+	}
+	if config != nil {
+		clientOpts = append(clientOpts, client.WithConfig(config.config))
+	}
+	converted := make(map[*Source]*core.Source)
+	for _, src := range options.sources {
+		clientOpts = append(clientOpts, client.WithSource(src.toCore(cacheDir, converted)))
+	}
+	for name, r := range options.renderers {
+		clientOpts = append(clientOpts, client.WithRenderer(name, r))
+	}
+	if options.memory {
+		clientOpts = append(clientOpts, client.WithMemoryCache())
+	}
+	if options.background != nil {
+		clientOpts = append(clientOpts, client.WithBackgroundRefresh(options.background))
+	}
+
+	c, err := client.New(clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{c: c, cacheDir: cacheDir}, nil
+}
+
+// DefaultConfigDir returns the directory the sgen command loads its
+// configuration from, $SGEN_CONFIG_DIR or ~/.config/sgen.
+func DefaultConfigDir() (string, error) {
+	return client.DefaultConfigDir()
+}
+
+// DefaultCacheDir returns the directory synced data and rendered output are
+// cached in, $SGEN_CACHE_DIR or sgen in the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	return core.CacheDir()
+}
+
+// SourceNames returns the names of every source, sorted.
+func (c *Client) SourceNames() []string {
+	return c.c.SourceNames()
+}
+
+// Source returns the named source. Configured sources are built the first time
+// they are used.
+func (c *Client) Source(name string) (*Source, error) {
+	src, err := c.c.Source(name)
+	if err != nil {
+		return nil, err
+	}
+	return fromCore(src, make(map[*core.Source]*Source)), nil
+}
+
+// SourceStatus describes the data a source has.
+type SourceStatus struct {
+	// Cached is whether the source's data is cached, and so needs syncing.
+	Cached bool
+	// SyncedAt is when the source was last synced, nil if it never has been
+	// or it isn't cached.
+	SyncedAt *time.Time
+	// Items is the number of records the source has, nil if it has never
+	// been synced.
+	Items *int
+}
+
+// Status returns the status of the named source. Sources that aren't cached
+// are loaded to count their records.
+func (c *Client) Status(ctx context.Context, name string) (SourceStatus, error) {
+	status, err := c.c.Status(ctx, name)
+	return SourceStatus(status), err
+}
+
+// Load returns the records of the named source. Cached sources return an
+// error wrapping fs.ErrNotExist if they have never been synced.
+func (c *Client) Load(ctx context.Context, name string) ([]map[string]any, error) {
+	return c.c.Load(ctx, name)
+}
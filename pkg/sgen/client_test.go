@@ -0,0 +1,316 @@
+package sgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// staticSupplier supplies a fixed set of records.
+type staticSupplier struct {
+	records []map[string]any
+	cache   bool
+}
+
+func (s staticSupplier) ShouldCache() bool { return s.cache }
+
+func (s staticSupplier) Supply(context.Context) ([]map[string]any, error) {
+	// copied since records are sorted in place
+	return append([]map[string]any(nil), s.records...), nil
+}
+
+func newTestClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+
+	tmpl, err := NewGoTemplateRenderer("{{ .name }}")
+	if err != nil {
+		t.Fatalf("NewGoTemplateRenderer() error: %v", err)
+	}
+	opts = append([]Option{
+		WithCacheDir(t.TempDir()),
+		WithSource(&Source{
+			Name: "repos",
+			Supplier: staticSupplier{records: []map[string]any{
+				{"name": "web", "stars": float64(3)},
+				{"name": "api", "stars": float64(7)},
+			}},
+			Renderers: map[string]Renderer{"default": tmpl},
+		}),
+		WithSource(&Source{
+			Name: "teams",
+			Supplier: staticSupplier{cache: true, records: []map[string]any{
+				{"name": "platform"},
+			}},
+		}),
+	}, opts...)
+
+	client, err := New(opts...)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return client
+}
+
+func TestClientGenerate(t *testing.T) {
+	upper, err := NewGoTemplateRenderer("{{ .name | upper }}")
+	if err != nil {
+		t.Fatalf("NewGoTemplateRenderer() error: %v", err)
+	}
+	client := newTestClient(t, WithRenderer("upper", upper))
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		opts   []GenerateOption
+		expect string
+	}{
+		{name: "default", expect: "web\napi\n"},
+		{name: "sorted", opts: []GenerateOption{WithSort([]string{"stars"}, true)}, expect: "api\nweb\n"},
+		{name: "named renderer", opts: []GenerateOption{WithNamedRenderer("upper")}, expect: "WEB\nAPI\n"},
+		{name: "query", opts: []GenerateOption{WithQuery(ParseQuery("ap"), "")}, expect: "api\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := client.Generate(ctx, &b, []string{"repos"}, tt.opts...); err != nil {
+				t.Fatalf("Generate() error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expect, b.String()); diff != "" {
+				t.Errorf("Generate() output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateWithoutCache(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	tmpl, err := NewHermeticGoTemplateRenderer("{{ .name }}-{{ .stars }}")
+	if err != nil {
+		t.Fatalf("NewHermeticGoTemplateRenderer() error: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := client.Generate(ctx, &b, []string{"repos"}, WithTemplate(tmpl), WithoutCache()); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if diff := cmp.Diff("web-3\napi-7\n", b.String()); diff != "" {
+		t.Errorf("Generate() output mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(client.cacheDir, "templates")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("template cache exists after generating without it, stat error = %v", err)
+	}
+}
+
+func TestGenerateQueryDefaultDocument(t *testing.T) {
+	doc, err := NewDocumentRenderer("{{ .Count }} repos\n")
+	if err != nil {
+		t.Fatalf("NewDocumentRenderer() error: %v", err)
+	}
+	client := newTestClient(t, WithSource(&Source{
+		Name: "report",
+		Supplier: staticSupplier{records: []map[string]any{
+			{"name": "web"},
+			{"name": "api"},
+		}},
+		Documents: map[string]*DocumentRenderer{"default": doc},
+	}))
+	ctx := context.Background()
+
+	var b bytes.Buffer
+	if err := client.Generate(ctx, &b, []string{"report"}); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if diff := cmp.Diff("2 repos\n", b.String()); diff != "" {
+		t.Errorf("Generate() output mismatch (-want +got):\n%s", diff)
+	}
+
+	// queries match records one at a time, so they fall back to JSON
+	b.Reset()
+	if err := client.Generate(ctx, &b, []string{"report"}, WithQuery(ParseQuery("ap"), "name")); err != nil {
+		t.Fatalf("Generate() with query error: %v", err)
+	}
+	if diff := cmp.Diff(`{"name":"api"}`+"\n", b.String()); diff != "" {
+		t.Errorf("Generate() with query output mismatch (-want +got):\n%s", diff)
+	}
+
+	err = client.Generate(ctx, &b, []string{"report"}, WithDocument(doc), WithQuery(ParseQuery("ap"), "name"))
+	if err == nil || !strings.Contains(err.Error(), "can't be used with queries") {
+		t.Errorf("Generate() with document and query error = %v, want it to say documents can't be queried", err)
+	}
+}
+
+func TestHermeticGoTemplateRenderer(t *testing.T) {
+	if _, err := NewHermeticGoTemplateRenderer(`{{ env "HOME" }}`); err == nil {
+		t.Error("expected an error using env in a hermetic template")
+	}
+}
+
+func TestGoTemplateRendererNumbers(t *testing.T) {
+	// numbers are decoded as json.Numbers, which templates must see as numbers
+	record := map[string]any{
+		"zero":  json.Number("0"),
+		"stars": json.Number("12"),
+		"ratio": json.Number("0.5"),
+		"id":    json.Number("1234567890123456789"),
+		"tags":  []any{map[string]any{"count": json.Number("0")}},
+	}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{`{{if .zero}}yes{{else}}no{{end}}`, "no"},
+		{`{{if .stars}}yes{{else}}no{{end}}`, "yes"},
+		{`{{if gt .stars 5}}popular{{end}}`, "popular"},
+		{`{{if eq .stars 12}}twelve{{end}}`, "twelve"},
+		{`{{if lt .ratio 1.0}}under{{end}}`, "under"},
+		{`{{.id}}`, "1234567890123456789"},
+		{`{{range .tags}}{{if not .count}}empty{{end}}{{end}}`, "empty"},
+	}
+	for _, tt := range tests {
+		r, err := NewGoTemplateRenderer(tt.tmpl)
+		if err != nil {
+			t.Fatalf("NewGoTemplateRenderer(%q) error: %v", tt.tmpl, err)
+		}
+		got, err := r.Render(record)
+		if err != nil {
+			t.Errorf("Render(%q) error: %v", tt.tmpl, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+
+	doc, err := NewDocumentRenderer(`{{range .Items}}{{if gt .stars 5}}{{.stars}}{{end}}{{end}}`)
+	if err != nil {
+		t.Fatalf("NewDocumentRenderer() error: %v", err)
+	}
+	got, err := doc.Render(Document{Items: []map[string]any{record}})
+	if err != nil {
+		t.Fatalf("document Render() error: %v", err)
+	}
+	if got != "12" {
+		t.Errorf("document Render() = %q, want %q", got, "12")
+	}
+}
+
+func TestClientSync(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := client.Load(ctx, "teams"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Load() before sync error = %v, want fs.ErrNotExist", err)
+	}
+	status, err := client.Status(ctx, "teams")
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if !status.Cached || status.SyncedAt != nil {
+		t.Errorf("Status() before sync = %+v, want cached and never synced", status)
+	}
+
+	if err := client.Sync(ctx, nil); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+
+	// sources without a default renderer render JSON
+	var b bytes.Buffer
+	if err := client.Generate(ctx, &b, []string{"teams"}); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if diff := cmp.Diff("{\"name\":\"platform\"}\n", b.String()); diff != "" {
+		t.Errorf("Generate() output mismatch (-want +got):\n%s", diff)
+	}
+	status, err = client.Status(ctx, "teams")
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if status.SyncedAt == nil || status.Items == nil || *status.Items != 1 {
+		t.Errorf("Status() after sync = %+v, want 1 synced item", status)
+	}
+	// synced data is kept in the directory set with WithCacheDir
+	if _, err := os.Stat(filepath.Join(client.cacheDir, "sources", "by-name", "teams.json")); err != nil {
+		t.Errorf("synced data not in the cache directory: %v", err)
+	}
+}
+
+func TestClientSource(t *testing.T) {
+	client := newTestClient(t)
+
+	src, err := client.Source("repos")
+	if err != nil {
+		t.Fatalf("Source() error: %v", err)
+	}
+	if src.Name != "repos" || src.Supplier.ShouldCache() {
+		t.Errorf("Source() = %+v, want the uncached repos source", src)
+	}
+	line, err := src.Renderers["default"].Render(map[string]any{"name": "web"})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	if line != "web" {
+		t.Errorf("default renderer rendered %q, want %q", line, "web")
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.Generate(ctx, &bytes.Buffer{}, nil); err == nil {
+		t.Error("expected an error generating without sources")
+	}
+	if err := client.Generate(ctx, &bytes.Buffer{}, []string{"missing"}); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+	if _, err := New(
+		WithSource(&Source{Name: "repos", Supplier: staticSupplier{}}),
+		WithSource(&Source{Name: "repos", Supplier: staticSupplier{}}),
+	); err == nil {
+		t.Error("expected an error for a source registered twice")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.hcl": `source "file" "names" {
+  path = "${sgen.directory}/names.json"
+}`,
+		"names.json": `[{"name": "bob"}]`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"names"}, config.SourceNames()); diff != "" {
+		t.Errorf("SourceNames() mismatch (-want +got):\n%s", diff)
+	}
+
+	client, err := New(WithConfig(config), WithCacheDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	records, err := client.Load(context.Background(), "names")
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if diff := cmp.Diff([]map[string]any{{"name": "bob"}}, records); diff != "" {
+		t.Errorf("Load() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,105 @@
+package sgen
+
+import (
+	"context"
+	"io"
+
+	"github.com/scnewma/sgen/internal/client"
+)
+
+// GenerateOption configures Generate.
+type GenerateOption func(*generateOptions)
+
+type generateOptions struct {
+	opts []client.GenerateOption
+}
+
+func (o *generateOptions) add(opt client.GenerateOption) {
+	o.opts = append(o.opts, opt)
+}
+
+// WithTemplate renders each record with r rather than the source's default
+// renderer.
+func WithTemplate(r Renderer) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithTemplate(r))
+	}
+}
+
+// WithoutCache renders without reading or writing the template cache, for
+// one-off templates whose output isn't worth keeping.
+func WithoutCache() GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithoutCache())
+	}
+}
+
+// WithDocument renders all of each source's records at once with r.
+func WithDocument(r *DocumentRenderer) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithDocument(r.r))
+	}
+}
+
+// WithNamedRenderer renders each record with the source's renderer, or
+// document template, called name. Sources without one use their default.
+func WithNamedRenderer(name string) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithNamedRenderer(name))
+	}
+}
+
+// WithFilter only renders the records that match f.
+func WithFilter(f *Filter) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithFilter(f.f))
+	}
+}
+
+// WithSort renders records ordered by fields, see records.CompareBy.
+func WithSort(fields []string, reverse bool) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithSort(fields, reverse))
+	}
+}
+
+// WithUnique only renders the first record for each value of field.
+func WithUnique(field string) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithUnique(field))
+	}
+}
+
+// WithQuery only renders the records that fuzzy match q, best matches first.
+// Records are matched on the value of field or, when it is empty, on their
+// rendered output.
+func WithQuery(q *Query, field string) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithQuery(q.q, field))
+	}
+}
+
+// WithListRenderer renders the records as a single list of items, i.e. an
+// Alfred script filter document, rather than a line per record.
+func WithListRenderer(r ListRenderer) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithListRenderer(toCoreListRenderer(r)))
+	}
+}
+
+// WithLimit renders at most n records.
+func WithLimit(n int) GenerateOption {
+	return func(opts *generateOptions) {
+		opts.add(client.WithLimit(n))
+	}
+}
+
+// Generate renders the records of the named sources to out, one source after
+// the other unless the options combine them.
+func (c *Client) Generate(ctx context.Context, out io.Writer, names []string, opts ...GenerateOption) error {
+	var options generateOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return c.c.Generate(ctx, out, names, options.opts...)
+}
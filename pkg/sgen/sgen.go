@@ -0,0 +1,366 @@
+// Package sgen loads, syncs and renders sgen sources from Go programs, the same
+// way as the sgen command. Sources come from an sgen configuration, see
+// WithConfigDir, or are registered with WithSource, and share the sgen
+// command's cache so either can sync sources for the other.
+//
+//	client, err := sgen.New(sgen.WithConfigDir(dir))
+//	if err != nil {
+//		return err
+//	}
+//	if err := client.Refresh(ctx, []string{"repos"}, false); err != nil {
+//		return err
+//	}
+//	return client.Generate(ctx, os.Stdout, []string{"repos"}, sgen.WithLimit(10))
+package sgen
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/scnewma/sgen/internal/client"
+	"github.com/scnewma/sgen/internal/fuzzy"
+	"github.com/scnewma/sgen/internal/records"
+	core "github.com/scnewma/sgen/internal/sgen"
+)
+
+// DefaultParallelism is the maximum number of sources synced at the same time
+// unless the client is created with WithDefaultParallelism.
+const DefaultParallelism = client.DefaultParallelism
+
+// Source is a named set of records along with how they are rendered.
+type Source struct {
+	Name     string
+	Supplier Supplier
+	// Renderers are the source's named templates that render its records one
+	// at a time. Without a "default" renderer records are rendered as JSON.
+	Renderers map[string]Renderer
+	// Documents are the source's named templates that render all of its
+	// records at once. Names are unique across Renderers and Documents.
+	Documents map[string]*DocumentRenderer
+	// TTL is how long the source's cached data is considered fresh for. Zero
+	// means the cached data never expires.
+	TTL time.Duration
+	// SortBy are the fields the source's records are sorted by, before any
+	// order requested when generating output is applied.
+	SortBy []string
+	// Items renders the source's records as list items, i.e. for Alfred. When
+	// nil items are titled with the source's rendered records.
+	Items *ItemRenderer
+	// Inputs are the sources this source's data is derived from, i.e. the
+	// sources of a join. The source expires when any of them have been synced
+	// since it was. Inputs that aren't cached expire it whenever their data
+	// may have changed, see ModifiedSupplier.
+	Inputs []*Source
+}
+
+// toCore converts the source, and its inputs, for the client. built holds the
+// sources already converted, so inputs shared by several sources are only
+// converted once.
+func (s *Source) toCore(cacheDir string, built map[*Source]*core.Source) *core.Source {
+	if src, found := built[s]; found {
+		return src
+	}
+	src := &core.Source{
+		Name:     s.Name,
+		Supplier: s.Supplier,
+		TTL:      s.TTL,
+		SortBy:   s.SortBy,
+		CacheDir: cacheDir,
+	}
+	built[s] = src
+	src.Renderers = make(map[string]core.Renderer, len(s.Renderers))
+	for name, r := range s.Renderers {
+		src.Renderers[name] = r
+	}
+	if len(s.Documents) > 0 {
+		src.Documents = make(map[string]*core.DocumentRenderer, len(s.Documents))
+		for name, doc := range s.Documents {
+			src.Documents[name] = doc.r
+		}
+	}
+	if s.Items != nil {
+		src.Items = s.Items.r
+	}
+	for _, input := range s.Inputs {
+		src.Inputs = append(src.Inputs, input.toCore(cacheDir, built))
+	}
+	return src
+}
+
+// fromCore converts a source built by the client, see toCore.
+func fromCore(src *core.Source, converted map[*core.Source]*Source) *Source {
+	if s, found := converted[src]; found {
+		return s
+	}
+	s := &Source{
+		Name:     src.Name,
+		Supplier: src.Supplier,
+		TTL:      src.TTL,
+		SortBy:   src.SortBy,
+	}
+	converted[src] = s
+	s.Renderers = make(map[string]Renderer, len(src.Renderers))
+	for name, r := range src.Renderers {
+		s.Renderers[name] = r
+	}
+	if len(src.Documents) > 0 {
+		s.Documents = make(map[string]*DocumentRenderer, len(src.Documents))
+		for name, doc := range src.Documents {
+			s.Documents[name] = &DocumentRenderer{r: doc}
+		}
+	}
+	if src.Items != nil {
+		s.Items = &ItemRenderer{r: src.Items}
+	}
+	for _, input := range src.Inputs {
+		s.Inputs = append(s.Inputs, fromCore(input, converted))
+	}
+	return s
+}
+
+// Supplier supplies a source's records. Sources whose supplier should be
+// cached are only supplied when they are synced.
+type Supplier interface {
+	ShouldCache() bool
+	Supply(context.Context) ([]map[string]any, error)
+}
+
+// ModifiedSupplier is a Supplier that isn't cached but can tell when its data
+// last changed, i.e. a file. Sources derived from a Supplier that isn't cached
+// and can't tell always expire.
+type ModifiedSupplier interface {
+	Supplier
+	ModifiedAt() (time.Time, error)
+}
+
+// Renderer renders a single record as a line of output.
+type Renderer interface {
+	// ID identifies the renderer's output in the template cache, so
+	// renderers that render records differently must have different IDs.
+	ID() string
+	Render(map[string]any) (string, error)
+}
+
+// JSONRenderer renders records as JSON, the default for sources without a
+// default template.
+type JSONRenderer struct{}
+
+func (r *JSONRenderer) ID() string {
+	return (&core.JSONRenderer{}).ID()
+}
+
+func (r *JSONRenderer) Render(data map[string]any) (string, error) {
+	return (&core.JSONRenderer{}).Render(data)
+}
+
+// GoTemplateRenderer renders records with a go template.
+type GoTemplateRenderer struct {
+	r *core.GoTemplateRenderer
+}
+
+// NewGoTemplateRenderer parses tmpl as a go template with the sprig functions.
+func NewGoTemplateRenderer(tmpl string) (*GoTemplateRenderer, error) {
+	r, err := core.NewGoTemplateRenderer(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &GoTemplateRenderer{r: r}, nil
+}
+
+// NewHermeticGoTemplateRenderer parses tmpl as a go template with the sprig
+// functions that don't read the environment, the clock or the network.
+func NewHermeticGoTemplateRenderer(tmpl string) (*GoTemplateRenderer, error) {
+	r, err := core.NewHermeticGoTemplateRenderer(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &GoTemplateRenderer{r: r}, nil
+}
+
+func (r *GoTemplateRenderer) ID() string {
+	return r.r.ID()
+}
+
+func (r *GoTemplateRenderer) Render(data map[string]any) (string, error) {
+	return r.r.Render(data)
+}
+
+// Document is the data a DocumentRenderer's template is executed with.
+type Document struct {
+	// Source is the name of the source the items are from.
+	Source string
+	Items  []map[string]any
+	Count  int
+	// SyncedAt is when the source was last synced, or nil if it isn't cached.
+	SyncedAt *time.Time
+}
+
+// DocumentRenderer renders all of a source's records at once.
+type DocumentRenderer struct {
+	r *core.DocumentRenderer
+}
+
+// NewDocumentRenderer parses tmpl as a go template executed with a Document.
+func NewDocumentRenderer(tmpl string) (*DocumentRenderer, error) {
+	r, err := core.NewDocumentRenderer(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &DocumentRenderer{r: r}, nil
+}
+
+func (r *DocumentRenderer) ID() string {
+	return r.r.ID()
+}
+
+func (r *DocumentRenderer) Render(doc Document) (string, error) {
+	return r.r.Render(core.Document(doc))
+}
+
+// Item is a record as an entry in a launcher's list.
+type Item struct {
+	UID          string
+	Title        string
+	Subtitle     string
+	Arg          string
+	Autocomplete string
+	Icon         string
+}
+
+// ItemTemplates are go templates for the fields of an Item. Empty templates
+// leave the field to its default, see ItemRenderer.Render.
+type ItemTemplates struct {
+	UID          string
+	Title        string
+	Subtitle     string
+	Arg          string
+	Autocomplete string
+	Icon         string
+}
+
+// ItemRenderer renders records as Items.
+type ItemRenderer struct {
+	r *core.ItemRenderer
+}
+
+// NewItemRenderer parses the templates of t.
+func NewItemRenderer(t ItemTemplates) (*ItemRenderer, error) {
+	r, err := core.NewItemRenderer(core.ItemTemplates(t))
+	if err != nil {
+		return nil, err
+	}
+	return &ItemRenderer{r: r}, nil
+}
+
+func (r *ItemRenderer) ID() string {
+	return r.r.ID()
+}
+
+// Render renders data as an Item. line is the record rendered by the source's
+// usual renderer, which is the title when there is no title template. The arg
+// defaults to the title.
+func (r *ItemRenderer) Render(data map[string]any, line string) (Item, error) {
+	item, err := r.r.Render(data, line)
+	return Item(item), err
+}
+
+// ListRenderer writes a list of Items in the format of a launcher.
+type ListRenderer interface {
+	ID() string
+	// ContentType is the media type of the list, for serving it over HTTP.
+	ContentType() string
+	RenderList(w io.Writer, items []Item) error
+}
+
+// ListFormats are the formats supported by NewListRenderer.
+var ListFormats = core.ListFormats
+
+// NewListRenderer returns the renderer for one of ListFormats.
+func NewListRenderer(format string) (ListRenderer, error) {
+	r, err := core.NewListRenderer(format)
+	if err != nil {
+		return nil, err
+	}
+	return builtinListRenderer{r}, nil
+}
+
+// builtinListRenderer is one of the client's list renderers, see
+// NewListRenderer.
+type builtinListRenderer struct {
+	core.ListRenderer
+}
+
+func (r builtinListRenderer) RenderList(w io.Writer, items []Item) error {
+	coreItems := make([]core.Item, 0, len(items))
+	for _, item := range items {
+		coreItems = append(coreItems, core.Item(item))
+	}
+	return r.ListRenderer.RenderList(w, coreItems)
+}
+
+// listRenderer adapts a ListRenderer that isn't built in for the client.
+type listRenderer struct {
+	ListRenderer
+}
+
+func (r listRenderer) RenderList(w io.Writer, coreItems []core.Item) error {
+	items := make([]Item, 0, len(coreItems))
+	for _, item := range coreItems {
+		items = append(items, Item(item))
+	}
+	return r.ListRenderer.RenderList(w, items)
+}
+
+// toCoreListRenderer returns the client's renderer for r.
+func toCoreListRenderer(r ListRenderer) core.ListRenderer {
+	if builtin, ok := r.(builtinListRenderer); ok {
+		return builtin.ListRenderer
+	}
+	return listRenderer{r}
+}
+
+// Filter selects the records matching a where expression.
+type Filter struct {
+	f *records.Filter
+}
+
+// ParseFilter parses a where expression, like sgen's --where flag.
+func ParseFilter(expr string) (*Filter, error) {
+	f, err := records.ParseFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{f: f}, nil
+}
+
+// Match reports whether the record satisfies the filter.
+func (f *Filter) Match(record map[string]any) bool {
+	return f.f.Match(record)
+}
+
+// String returns the expression the filter was parsed from.
+func (f *Filter) String() string {
+	return f.f.String()
+}
+
+// Query is a set of fuzzy search terms.
+type Query struct {
+	q *fuzzy.Query
+}
+
+// ParseQuery splits s into fuzzy search terms, like sgen query's arguments.
+func ParseQuery(s string) *Query {
+	return &Query{q: fuzzy.Parse(s)}
+}
+
+// Score reports whether text matches every term of the query, and how well.
+// Higher scores are better matches.
+func (q *Query) Score(text string) (int, bool) {
+	return q.q.Score(text)
+}
+
+// String returns the query the terms were parsed from.
+func (q *Query) String() string {
+	return q.q.String()
+}
@@ -0,0 +1,55 @@
+package sgen
+
+import (
+	"context"
+	"io"
+
+	"github.com/scnewma/sgen/internal/client"
+)
+
+// SyncOption configures Sync.
+type SyncOption func(*syncOptions)
+
+type syncOptions struct {
+	opts []client.SyncOption
+}
+
+// WithParallelism sets the maximum number of sources that are synced at the
+// same time.
+func WithParallelism(n int) SyncOption {
+	return func(opts *syncOptions) {
+		opts.opts = append(opts.opts, client.WithParallelism(n))
+	}
+}
+
+// WithProgress writes a line to w as each source finishes syncing, followed by
+// a summary table once all of the sources are done.
+func WithProgress(w io.Writer) SyncOption {
+	return func(opts *syncOptions) {
+		opts.opts = append(opts.opts, client.WithProgress(w))
+	}
+}
+
+// Sync updates the named sources, or every source when there are none,
+// concurrently. A failure to sync one source does not stop the others from
+// syncing; the returned error names every source that failed. Sources derived
+// from other sources are synced after them, along with any of those sources
+// that weren't named.
+func (c *Client) Sync(ctx context.Context, names []string, opts ...SyncOption) error {
+	var options syncOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return c.c.Sync(ctx, names, options.opts...)
+}
+
+// Refresh updates any sources, or the sources they are derived from, whose
+// cached data is older than their TTL or than the data of their inputs.
+// Expired sources are synced before returning unless the client was created
+// with WithBackgroundRefresh and fresh isn't set, in which case they are
+// refreshed in the background while their stale data continues to be served.
+// Sources with a TTL that have never been synced are always synced before
+// returning, since there is no data to serve in the meantime.
+func (c *Client) Refresh(ctx context.Context, names []string, fresh bool) error {
+	return c.c.Refresh(ctx, names, fresh)
+}
@@ -0,0 +1,224 @@
+package sgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	core "github.com/scnewma/sgen/internal/sgen"
+)
+
+// failingSupplier always fails to supply its records.
+type failingSupplier struct {
+	err error
+}
+
+func (s failingSupplier) ShouldCache() bool { return true }
+
+func (s failingSupplier) Supply(context.Context) ([]map[string]any, error) {
+	return nil, s.err
+}
+
+// timeoutSupplier times out like a command or http source that takes too long.
+type timeoutSupplier struct{}
+
+func (s timeoutSupplier) ShouldCache() bool { return true }
+
+func (s timeoutSupplier) Supply(context.Context) ([]map[string]any, error) {
+	return nil, &core.TimeoutError{Timeout: 30 * time.Second}
+}
+
+// slowSupplier records how many sources are being supplied at the same time.
+type slowSupplier struct {
+	active, max *atomic.Int32
+}
+
+func (s slowSupplier) ShouldCache() bool { return true }
+
+func (s slowSupplier) Supply(context.Context) ([]map[string]any, error) {
+	n := s.active.Add(1)
+	defer s.active.Add(-1)
+	for {
+		max := s.max.Load()
+		if n <= max || s.max.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return []map[string]any{{"name": "slow"}}, nil
+}
+
+func TestSyncFailure(t *testing.T) {
+	client := newTestClient(t, WithSource(&Source{
+		Name:     "broken",
+		Supplier: failingSupplier{err: errors.New("token expired")},
+	}))
+	ctx := context.Background()
+
+	var progress bytes.Buffer
+	err := client.Sync(ctx, []string{"broken", "teams"}, WithProgress(&progress))
+	if err == nil {
+		t.Fatal("expected an error syncing a failing source")
+	}
+	for _, want := range []string{"failed to sync 1 of 2 sources [broken]", "broken: token expired"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Sync() error %q does not contain %q", err, want)
+		}
+	}
+	if !strings.Contains(progress.String(), "broken failed") {
+		t.Errorf("progress %q does not report the failure", progress.String())
+	}
+
+	// the working source is synced regardless
+	var b bytes.Buffer
+	if err := client.Generate(ctx, &b, []string{"teams"}); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if diff := cmp.Diff("{\"name\":\"platform\"}\n", b.String()); diff != "" {
+		t.Errorf("Generate() output mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSyncTimeout(t *testing.T) {
+	client := newTestClient(t, WithSource(&Source{Name: "gh", Supplier: timeoutSupplier{}}))
+
+	err := client.Sync(context.Background(), []string{"gh"})
+	if err == nil || !strings.Contains(err.Error(), `source "gh" timed out after 30s`) {
+		t.Errorf("Sync() error = %v, want it to name the source that timed out", err)
+	}
+}
+
+func TestSyncParallelism(t *testing.T) {
+	var active, max atomic.Int32
+	var opts []Option
+	var names []string
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("slow%d", i)
+		names = append(names, name)
+		opts = append(opts, WithSource(&Source{
+			Name:     name,
+			Supplier: slowSupplier{active: &active, max: &max},
+		}))
+	}
+	client := newTestClient(t, opts...)
+
+	if err := client.Sync(context.Background(), names, WithParallelism(2)); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if got := max.Load(); got > 2 {
+		t.Errorf("synced %d sources at the same time, want at most 2", got)
+	}
+	for _, name := range names {
+		if _, err := client.Load(context.Background(), name); err != nil {
+			t.Errorf("Load(%q) error: %v", name, err)
+		}
+	}
+}
+
+func TestSyncConcurrently(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = client.Sync(ctx, []string{"teams"})
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Sync() error: %v", err)
+		}
+	}
+
+	records, err := client.Load(ctx, "teams")
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if diff := cmp.Diff([]map[string]any{{"name": "platform"}}, records); diff != "" {
+		t.Errorf("Load() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// versionedSupplier supplies two records with a version that is incremented
+// every time it is synced.
+type versionedSupplier struct {
+	version *atomic.Int32
+}
+
+func (s versionedSupplier) ShouldCache() bool { return true }
+
+func (s versionedSupplier) Supply(context.Context) ([]map[string]any, error) {
+	v := s.version.Add(1)
+	return []map[string]any{{"v": v}, {"v": v}}, nil
+}
+
+func TestSyncWhileGenerating(t *testing.T) {
+	var version atomic.Int32
+	tmpl, err := NewGoTemplateRenderer("{{ .v }}")
+	if err != nil {
+		t.Fatalf("NewGoTemplateRenderer() error: %v", err)
+	}
+	client := newTestClient(t, WithSource(&Source{
+		Name:      "versioned",
+		Supplier:  versionedSupplier{version: &version},
+		Renderers: map[string]Renderer{"default": tmpl},
+	}))
+	ctx := context.Background()
+	if err := client.Sync(ctx, []string{"versioned"}); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			if err := client.Sync(ctx, []string{"versioned"}); err != nil {
+				t.Errorf("Sync() error: %v", err)
+			}
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				var b bytes.Buffer
+				if err := client.Generate(ctx, &b, []string{"versioned"}); err != nil {
+					t.Errorf("Generate() error: %v", err)
+					return
+				}
+				// output is rendered from a single version of the data
+				lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+				if len(lines) != 2 || lines[0] != lines[1] {
+					t.Errorf("Generate() output %q mixes versions", b.String())
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// output cached while syncing must not outlive the data it came from
+	var b bytes.Buffer
+	if err := client.Generate(ctx, &b, []string{"versioned"}); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	want := fmt.Sprintf("%[1]d\n%[1]d\n", version.Load())
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("Generate() after syncing mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -1,12 +1,18 @@
 package regression
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/golden"
@@ -74,6 +80,156 @@ func TestRegression(t *testing.T) {
 			args:       []string{"--sync", "names-command", "--template={{.name | repeat 3}}"},
 			goldenFile: "cli-template-command.golden",
 		},
+		{
+			name:       "file: nested data",
+			args:       []string{"repos-file"},
+			goldenFile: "nested-file.golden",
+		},
+		{
+			name:       "command: nested data",
+			args:       []string{"--sync", "repos-command"},
+			goldenFile: "nested-command.golden",
+		},
+		{
+			name:       "command: nested data renders json",
+			args:       []string{"--sync", "repos-command", "--template={{toJson .}}"},
+			goldenFile: "nested-json-command.golden",
+		},
+		{
+			name:       "file: integers keep every digit",
+			args:       []string{"repos-file", "--template={{.id}} {{.name}}"},
+			goldenFile: "integers-file.golden",
+		},
+		{
+			name:       "command: integers keep every digit",
+			args:       []string{"--sync", "repos-command", "--template={{.id}} {{.name}}"},
+			goldenFile: "integers-command.golden",
+		},
+		{
+			name:       "file: where filter",
+			args:       []string{"repos-file", `--where=owner.login == "scnewma" && !isArchived`},
+			goldenFile: "where-file.golden",
+		},
+		{
+			name:       "command: where filter",
+			args:       []string{"--sync", "repos-command", `--where=name matches "^o"`},
+			goldenFile: "where-command.golden",
+		},
+		{
+			name:       "file: source sort_by",
+			args:       []string{"repos-sorted-file"},
+			goldenFile: "source-sort-by-file.golden",
+		},
+		{
+			name:       "multiple sources: sort, unique and limit",
+			args:       []string{"--sync", "names-file", "names-command", "--sort-by=name", "--reverse", "--unique-by=name", "--limit=2"},
+			goldenFile: "sort-unique-limit.golden",
+		},
+		{
+			name:       "file: source from included file",
+			args:       []string{"names-included-file"},
+			goldenFile: "included-file.golden",
+		},
+		{
+			name:       "file: csv",
+			args:       []string{"names-csv-file"},
+			goldenFile: "csv-file.golden",
+		},
+		{
+			name:       "file: select",
+			args:       []string{"people-select-file"},
+			goldenFile: "select-file.golden",
+		},
+		{
+			name:       "join: file sources",
+			args:       []string{"--sync", "repo-owners-join"},
+			goldenFile: "join.golden",
+		},
+		{
+			name:       "join: syncs inputs that were never synced",
+			args:       []string{"--sync", "repo-owners-command-join"},
+			goldenFile: "join.golden",
+		},
+		{
+			name:       "union: file sources",
+			args:       []string{"--sync", "names-union"},
+			goldenFile: "union.golden",
+		},
+		{
+			name:       "file: variables and locals",
+			args:       []string{"names-var-file", "--var=bullet=-"},
+			goldenFile: "variables-file.golden",
+		},
+		{
+			name:       "query: rendered output",
+			args:       []string{"query", "repos-file", "arch"},
+			goldenFile: "query.golden",
+		},
+		{
+			name:       "query: match field",
+			args:       []string{"query", "names-file", "--match-field=name", "--template={{.name}}", "li"},
+			goldenFile: "query-match-field.golden",
+		},
+		{
+			name:       "output: alfred",
+			args:       []string{"repos-file", "--output=alfred"},
+			goldenFile: "output-alfred.golden",
+		},
+		{
+			name:       "output: rofi",
+			args:       []string{"repos-file", "--output=rofi"},
+			goldenFile: "output-rofi.golden",
+		},
+		{
+			name:       "output: dmenu with multiple sources",
+			args:       []string{"repos-file", "names-file", "--output=dmenu"},
+			goldenFile: "output-dmenu.golden",
+		},
+		{
+			name:       "command: named document template",
+			args:       []string{"--sync", "names-command", "--template-name=summary"},
+			goldenFile: "document-template-command.golden",
+		},
+		{
+			name:       "file: document template file",
+			args:       []string{"repos-file", "--template-file=testdata/table.tpl", "--sort-by=stars"},
+			goldenFile: "document-template-file.golden",
+		},
+		{
+			name:       "list sources",
+			args:       []string{"list"},
+			goldenFile: "list.golden",
+		},
+		{
+			name:       "validate config",
+			args:       []string{"validate"},
+			goldenFile: "validate.golden",
+		},
+		{
+			name:       "command: json lines",
+			args:       []string{"--sync", "names-jsonl-command"},
+			goldenFile: "default-template-json-command.golden",
+		},
+		{
+			name:       "list: json output",
+			args:       []string{"list", "--output", "json"},
+			goldenFile: "list-json.golden",
+		},
+		{
+			name:       "describe: file source",
+			args:       []string{"describe", "repos-file"},
+			goldenFile: "describe.golden",
+		},
+		{
+			name:       "describe: json output",
+			args:       []string{"describe", "--output", "json", "names-file"},
+			goldenFile: "describe-json.golden",
+		},
+		{
+			name:       "command: ttl syncs when never synced",
+			args:       []string{"names-ttl-command"},
+			goldenFile: "default-template-json-command.golden",
+		},
 	}
 
 	configDir, err := filepath.Abs("./testdata/sgen")
@@ -96,7 +252,359 @@ func TestRegression(t *testing.T) {
 				t.Fatalf("error running command: %v\nStdout:\n%s\nStderr:\n%s\n", err, stdout.String(), stderr.String())
 			}
 
-			assert.Assert(t, golden.String(stdout.String(), tt.goldenFile))
+			// the config dir is different on every machine
+			out := strings.ReplaceAll(stdout.String(), configDir, "$SGEN_CONFIG_DIR")
+			assert.Assert(t, golden.String(out, tt.goldenFile))
 		})
 	}
 }
+
+func TestServe(t *testing.T) {
+	configDir, err := filepath.Abs("./testdata/sgen")
+	if err != nil {
+		t.Fatalf("could not find ./testdata directory: %v", err)
+	}
+
+	cmd := exec.Command(binaryLocation, "serve", "--listen", "127.0.0.1:0")
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, "SGEN_CONFIG_DIR="+configDir)
+	cmd.Env = append(cmd.Env, "SGEN_CACHE_DIR="+t.TempDir())
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatalf("error creating stderr pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	// the server reports the address it is listening on once it is ready
+	line, err := bufio.NewReader(stderr).ReadString('\n')
+	if err != nil {
+		t.Fatalf("error reading server address: %v", err)
+	}
+	addr, found := strings.CutPrefix(strings.TrimSpace(line), "listening on ")
+	if !found {
+		t.Fatalf("unexpected server output %q", line)
+	}
+	baseURL := "http://" + addr
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		host        string
+		header      http.Header
+		status      int
+		contentType string
+		goldenFile  string
+	}{
+		{
+			// listed first, before anything is synced
+			name:       "sources",
+			method:     http.MethodGet,
+			path:       "/sources",
+			status:     http.StatusOK,
+			goldenFile: "serve-sources.golden",
+		},
+		{
+			name:       "sync",
+			method:     http.MethodPost,
+			path:       "/sync?source=names-command",
+			header:     http.Header{"X-Sgen": {"1"}},
+			status:     http.StatusOK,
+			goldenFile: "serve-sync.golden",
+		},
+		{
+			name:       "output",
+			method:     http.MethodGet,
+			path:       "/output?source=names-command&template_name=bulleted&limit=2",
+			status:     http.StatusOK,
+			goldenFile: "serve-output.golden",
+		},
+		{
+			name:        "output: alfred",
+			method:      http.MethodGet,
+			path:        "/output?source=names-command&output=alfred&limit=2",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			goldenFile:  "serve-output-alfred.golden",
+		},
+		{
+			name:       "output: unknown source",
+			method:     http.MethodGet,
+			path:       "/output?source=missing",
+			status:     http.StatusNotFound,
+			goldenFile: "serve-unknown-source.golden",
+		},
+		{
+			name:       "output: template",
+			method:     http.MethodGet,
+			path:       "/output?source=names-command&limit=2&template=" + url.QueryEscape("{{ .name | upper }}"),
+			status:     http.StatusOK,
+			goldenFile: "serve-output-template.golden",
+		},
+		{
+			name:       "output: template can't read the environment",
+			method:     http.MethodGet,
+			path:       "/output?source=names-command&template=" + url.QueryEscape(`{{ env "HOME" }}`),
+			status:     http.StatusBadRequest,
+			goldenFile: "serve-output-template-env.golden",
+		},
+		{
+			name:       "host that isn't the listen address",
+			method:     http.MethodGet,
+			path:       "/sources",
+			host:       "attacker.example",
+			status:     http.StatusForbidden,
+			goldenFile: "serve-forbidden-host.golden",
+		},
+		{
+			name:       "sync: every source",
+			method:     http.MethodPost,
+			path:       "/sync",
+			header:     http.Header{"Content-Type": {"application/json"}},
+			status:     http.StatusOK,
+			goldenFile: "serve-sync-all.golden",
+		},
+		{
+			// what a web page's form or fetch would send
+			name:       "sync: cross-origin",
+			method:     http.MethodPost,
+			path:       "/sync",
+			header:     http.Header{"Origin": {"https://attacker.example"}, "Content-Type": {"text/plain"}},
+			status:     http.StatusForbidden,
+			goldenFile: "serve-sync-cross-origin.golden",
+		},
+		{
+			name:       "sync: without X-Sgen",
+			method:     http.MethodPost,
+			path:       "/sync",
+			header:     http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			status:     http.StatusForbidden,
+			goldenFile: "serve-sync-without-header.golden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, baseURL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("error creating request: %v", err)
+			}
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error making request: %v", err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("error reading response: %v", err)
+			}
+
+			assert.Equal(t, resp.StatusCode, tt.status)
+			if tt.contentType != "" {
+				assert.Equal(t, resp.Header.Get("Content-Type"), tt.contentType)
+			}
+			assert.Assert(t, golden.String(string(body), tt.goldenFile))
+		})
+	}
+}
+
+func TestTTLBackgroundRefresh(t *testing.T) {
+	configDir := t.TempDir()
+	cacheDir := t.TempDir()
+	config := `
+source "command" "ttl" {
+  command = "cat ${sgen.directory}/data.json"
+  ttl = "1h"
+
+  template {
+    name = "default"
+    value = "{{.name}}"
+  }
+}
+`
+	writeFile(t, filepath.Join(configDir, "config.hcl"), config)
+
+	run := func() string {
+		t.Helper()
+		cmd := exec.Command(binaryLocation, "ttl")
+		cmd.Env = os.Environ()
+		cmd.Env = append(cmd.Env, "SGEN_CONFIG_DIR="+configDir)
+		cmd.Env = append(cmd.Env, "SGEN_CACHE_DIR="+cacheDir)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("error running command: %v\nStdout:\n%s\nStderr:\n%s\n", err, stdout.String(), stderr.String())
+		}
+		return stdout.String()
+	}
+	// expire makes the cached data older than the ttl
+	expire := func() {
+		t.Helper()
+		meta := fmt.Sprintf(`{"synced_at": %q, "count": 1}`, time.Now().Add(-2*time.Hour).Format(time.RFC3339))
+		writeFile(t, filepath.Join(cacheDir, "sources", "by-name", "ttl.meta.json"), meta)
+	}
+	// waitFor polls until done returns true
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+			if done() {
+				return
+			}
+		}
+		t.Fatalf("timed out waiting for %s", what)
+	}
+	refreshed := func() bool {
+		_, err := os.Stat(filepath.Join(cacheDir, "sources", "by-name", "ttl.refresh"))
+		return os.IsNotExist(err)
+	}
+	refreshLog := func() string {
+		buf, _ := os.ReadFile(filepath.Join(cacheDir, "refresh.log"))
+		return string(buf)
+	}
+
+	// never synced, so it's synced before generating output
+	writeFile(t, filepath.Join(configDir, "data.json"), `[{"name": "first"}]`)
+	assert.Equal(t, run(), "first\n")
+
+	// expired, the stale data is served while a background refresh fails
+	writeFile(t, filepath.Join(configDir, "data.json"), `not json`)
+	expire()
+	assert.Equal(t, run(), "first\n")
+	waitFor("the refresh to fail", func() bool {
+		return strings.Contains(refreshLog(), "decoding output")
+	})
+	assert.Assert(t, strings.Contains(refreshLog(), "refreshing ttl"))
+
+	// still expired, but the failed refresh holds off background refreshes so
+	// a source that keeps failing isn't refreshed by every run
+	writeFile(t, filepath.Join(configDir, "data.json"), `[{"name": "second"}]`)
+	assert.Equal(t, run(), "first\n")
+	assert.Equal(t, strings.Count(refreshLog(), "refreshing ttl"), 1)
+	assert.Assert(t, !refreshed())
+
+	// once the back-off has passed the next background refresh succeeds
+	backoff := time.Now().Add(-11 * time.Minute)
+	marker := filepath.Join(cacheDir, "sources", "by-name", "ttl.refresh")
+	if err := os.Chtimes(marker, backoff, backoff); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, run(), "first\n")
+	assert.Equal(t, strings.Count(refreshLog(), "refreshing ttl"), 2)
+	waitFor("the refresh to finish", refreshed)
+	assert.Equal(t, run(), "second\n")
+}
+
+func TestDerivedSourceExpiry(t *testing.T) {
+	configDir := t.TempDir()
+	cacheDir := t.TempDir()
+	config := `
+source "file" "people" {
+  path = "${sgen.directory}/people.json"
+}
+
+source "file" "bots" {
+  path = "${sgen.directory}/bots.json"
+}
+
+source "file" "teams" {
+  path = "${sgen.directory}/teams.json"
+}
+
+source "union" "everyone" {
+  sources = ["people", "bots"]
+
+  template {
+    name = "default"
+    value = "{{.name}}"
+  }
+}
+
+source "join" "people-teams" {
+  left      = "people"
+  right     = "teams"
+  left_key  = "login"
+  right_key = "login"
+
+  template {
+    name = "default"
+    value = "{{.name}} {{.team}}"
+  }
+}
+`
+	writeFile(t, filepath.Join(configDir, "config.hcl"), config)
+
+	run := func(source string) string {
+		t.Helper()
+		cmd := exec.Command(binaryLocation, "--fresh", source)
+		cmd.Env = os.Environ()
+		cmd.Env = append(cmd.Env, "SGEN_CONFIG_DIR="+configDir)
+		cmd.Env = append(cmd.Env, "SGEN_CACHE_DIR="+cacheDir)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("error running command: %v\nStdout:\n%s\nStderr:\n%s\n", err, stdout.String(), stderr.String())
+		}
+		return stdout.String()
+	}
+	metaPath := func(source string) string {
+		return filepath.Join(cacheDir, "sources", "by-name", source+".meta.json")
+	}
+	readMeta := func(source string) string {
+		t.Helper()
+		buf, err := os.ReadFile(metaPath(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf)
+	}
+	// backdate makes the source look like it was synced before its files were
+	// last written, since file modification times can be coarser than the
+	// sync time
+	backdate := func(source string) {
+		t.Helper()
+		meta := fmt.Sprintf(`{"synced_at": %q, "count": 1}`, time.Now().Add(-time.Hour).Format(time.RFC3339))
+		writeFile(t, metaPath(source), meta)
+	}
+
+	writeFile(t, filepath.Join(configDir, "people.json"), `[{"login": "a", "name": "alice"}]`)
+	writeFile(t, filepath.Join(configDir, "bots.json"), `[{"login": "b", "name": "bot"}]`)
+	writeFile(t, filepath.Join(configDir, "teams.json"), `[{"login": "a", "team": "platform"}]`)
+	assert.Equal(t, run("everyone"), "alice\nbot\n")
+	assert.Equal(t, run("people-teams"), "alice platform\n")
+
+	// the files haven't changed, so the derived sources aren't synced again
+	synced := readMeta("everyone")
+	assert.Equal(t, run("everyone"), "alice\nbot\n")
+	assert.Equal(t, readMeta("everyone"), synced)
+
+	// a file input changing expires the union and the join
+	writeFile(t, filepath.Join(configDir, "people.json"), `[{"login": "a", "name": "alicia"}]`)
+	backdate("everyone")
+	backdate("people-teams")
+	assert.Equal(t, run("everyone"), "alicia\nbot\n")
+	assert.Equal(t, run("people-teams"), "alicia platform\n")
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -32,4 +32,111 @@ source "command" "names-command" {
     name = "bulleted"
     value = "* {{.name}}"
   }
+
+  template {
+    name = "summary"
+    mode = "document"
+    value = <<-EOT
+    {{.Source}}: {{.Count}} names{{if .SyncedAt}}, synced{{end}}
+    {{range .Items}}- {{.name}}
+    {{end}}
+    EOT
+  }
+}
+
+source "file" "repos-file" {
+  path = "${sgen.directory}/repos.json"
+
+  template {
+    name = "default"
+    value = "{{.owner.login}}/{{.name}}{{if .isArchived}} (archived){{end}}"
+  }
+
+  alfred {
+    uid = "{{.name}}"
+    subtitle = "{{.stars}} stars"
+    arg = "https://github.com/{{.owner.login}}/{{.name}}"
+    icon = "icons/github.png"
+  }
+}
+
+source "command" "repos-command" {
+  command = "cat ${sgen.directory}/repos.json"
+
+  template {
+    name = "default"
+    value = "{{.owner.login}}/{{.name}}{{if .isArchived}} (archived){{end}}"
+  }
+}
+
+source "command" "names-jsonl-command" {
+  command = "cat ${sgen.directory}/names.jsonl"
+  format = "jsonl"
+}
+
+source "command" "names-ttl-command" {
+  command = "cat ${sgen.directory}/names.json"
+  ttl = "1h"
+}
+
+source "file" "repos-sorted-file" {
+  path = "${sgen.directory}/repos.json"
+  sort_by = ["stars"]
+
+  template {
+    name = "default"
+    value = "{{.name}} {{.stars}}"
+  }
+}
+
+source "file" "names-csv-file" {
+  path = "${sgen.directory}/names.csv"
+
+  template {
+    name = "default"
+    value = "{{.name}} ({{.team}})"
+  }
+}
+
+source "file" "people-select-file" {
+  path = "${sgen.directory}/people-wrapped.json"
+  select = ".data.people[] | {name: .first, surname: .last}"
+}
+
+source "file" "owners-file" {
+  path = "${sgen.directory}/owners.json"
+}
+
+source "join" "repo-owners-join" {
+  left      = "repos-file"
+  right     = "owners-file"
+  left_key  = "owner.login"
+  right_key = "login"
+
+  template {
+    name = "default"
+    value = "{{.name}} by {{.fullName}}"
+  }
+}
+
+source "join" "repo-owners-command-join" {
+  left         = "repos-command"
+  right        = "owners-file"
+  left_key     = "owner.login"
+  right_key    = "login"
+  right_prefix = "owner_"
+
+  template {
+    name = "default"
+    value = "{{.name}} by {{.owner_fullName}}"
+  }
+}
+
+source "union" "names-union" {
+  sources = ["names-file", "names-csv-file"]
+
+  template {
+    name = "default"
+    value = "{{._source}}: {{.name}}"
+  }
 }
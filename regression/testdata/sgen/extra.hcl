@@ -0,0 +1 @@
+include "sources.d" {}
@@ -0,0 +1,8 @@
+source "file" "names-included-file" {
+  path = "${sgen.directory}/../names.json"
+
+  template {
+    name = "default"
+    value = "included {{.name}}"
+  }
+}
@@ -0,0 +1,16 @@
+variable "bullet" {
+  default = "*"
+}
+
+locals {
+  names = "${sgen.directory}/names.json"
+}
+
+source "file" "names-var-file" {
+  path = local.names
+
+  template {
+    name  = "default"
+    value = "${var.bullet} {{.name}}"
+  }
+}
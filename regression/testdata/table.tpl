@@ -0,0 +1,6 @@
+| name | stars |
+|------|-------|
+{{- range .Items}}
+| {{.name}} | {{.stars}} |
+{{- end}}
+{{.Count}} repos from {{.Source}}